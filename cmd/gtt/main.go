@@ -5,8 +5,10 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/ohler55/graphql-test-tool/gtt"
 )
@@ -20,6 +22,28 @@ var showResponses = false
 var showRequests = false
 var noColor = false
 var indent = 0
+var concurrency = 1
+var keepGoing = false
+var junitPath = ""
+var tapPath = ""
+var eventsPath = ""
+var manifestPath = ""
+var shareCookies = false
+var suitePath = ""
+var include patterns
+var exclude patterns
+
+// patterns is a flag value that collects patterns from repeated flags.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
 
 func init() {
 	flag.StringVar(&server, "s", server, "server URL, host and port (example: http://localhost:8080)")
@@ -31,15 +55,39 @@ func init() {
 	flag.BoolVar(&verbose, "v", verbose, "verbose")
 	flag.BoolVar(&debug, "d", debug, "debug")
 	flag.IntVar(&indent, "i", indent, "indent")
+	flag.IntVar(&concurrency, "j", concurrency, "number of use cases to run concurrently")
+	flag.BoolVar(&keepGoing, "k", keepGoing, "keep going after a failure and summarize at the end")
+	flag.StringVar(&junitPath, "junit", junitPath, "JUnit XML report file")
+	flag.StringVar(&tapPath, "tap", tapPath, "TAP output file, - for stdout with other output on stderr")
+	flag.StringVar(&eventsPath, "events", eventsPath, "JSON lines event file, - for stdout with other output on stderr")
+	flag.BoolVar(&shareCookies, "share-cookies", shareCookies, "share one cookie jar across all use cases")
+	flag.Var(&include, "include", "pattern for files to include from directories, may be repeated (default *.json and *.sen)")
+	flag.Var(&exclude, "exclude", "pattern for files and directories to exclude, may be repeated")
+	flag.StringVar(&suitePath, "suite", suitePath, "suite file with setup, use cases, and teardown, run before any other use cases")
+	flag.StringVar(&manifestPath, "manifest", manifestPath, "persisted operation manifest, send document IDs in place of queries")
 }
 
 func main() {
+	if 1 < len(os.Args) && os.Args[1] == "manifest" {
+		if err := manifest(os.Args[2:]); err != nil {
+			fmt.Printf("*-*-* Error: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `
 
-usage: %s [<options>] <json-file>...
+usage: %s [<options>] <json-file|directory|glob>...
+       %s -suite <suite-file> [<options>] [<json-file|directory|glob>...]
+       %s manifest [<options>] <json-file|directory|glob>...
+
+Directories are searched recursively for files that match the include
+patterns. A "**" element in a glob matches any number of directories.
+Files that hold an array, such as step include files, are skipped.
+Quote globs to keep the shell from expanding them.
 
-`, filepath.Base(os.Args[0]))
+`, filepath.Base(os.Args[0]), filepath.Base(os.Args[0]), filepath.Base(os.Args[0]))
 		flag.PrintDefaults()
 		fmt.Fprint(os.Stderr, "\n")
 	}
@@ -53,25 +101,142 @@ usage: %s [<options>] <json-file>...
 		ShowRequests:  showRequests,
 		NoColor:       noColor,
 		Indent:        indent,
+		Concurrency:   concurrency,
+		Continue:      keepGoing,
+		ShareCookies:  shareCookies,
 	}
 	if verbose {
 		r.ShowComments = true
 		r.ShowResponses = true
 		r.ShowRequests = true
 	}
-	for _, filepath := range flag.Args() {
-		uc, err := gtt.NewUseCase(filepath)
+	if 0 < len(suitePath) {
+		suite, err := gtt.NewSuite(suitePath)
+		if err != nil {
+			fmt.Printf("*-*-* Error: %s\n", err)
+			os.Exit(1)
+		}
+		suite.AddTo(&r)
+	}
+	paths, err := gtt.FindUseCases(flag.Args(), include, exclude)
+	if err != nil {
+		fmt.Printf("*-*-* Error: %s\n", err)
+		os.Exit(1)
+	}
+	if r.UseCases, err = addUseCases(r.UseCases, paths); err != nil {
+		fmt.Printf("*-*-* Error: %s\n", err)
+		os.Exit(1)
+	}
+	if 0 < len(manifestPath) {
+		m, err := gtt.ReadManifest(manifestPath)
 		if err != nil {
 			fmt.Printf("*-*-* Error: %s\n", err)
 			os.Exit(1)
 		}
-		r.UseCases = append(r.UseCases, uc)
+		r.Manifest = m
+	}
+	if tapPath == "-" && eventsPath == "-" {
+		fmt.Fprintf(os.Stderr, "*-*-* Error: only one of -tap and -events can write to stdout\n")
+		os.Exit(1)
+	}
+	if tapPath == "-" || eventsPath == "-" {
+		// Stdout is reserved for the reporter so the log and summary are
+		// written to stderr.
+		r.Writer = os.Stderr
+	}
+	if 0 < len(tapPath) {
+		w, err := openOutput(tapPath)
+		if err != nil {
+			fmt.Printf("*-*-* Error: %s\n", err)
+			os.Exit(1)
+		}
+		defer w.Close()
+		r.Reporters = append(r.Reporters, &gtt.TAPReporter{Writer: w})
+	}
+	if 0 < len(eventsPath) {
+		w, err := openOutput(eventsPath)
+		if err != nil {
+			fmt.Printf("*-*-* Error: %s\n", err)
+			os.Exit(1)
+		}
+		defer w.Close()
+		r.Reporters = append(r.Reporters, &gtt.JSONReporter{Writer: w})
 	}
 	if debug {
 		r.Log(gtt.Debug, string(r.JSON(2)))
 	}
-	if err := r.Run(); err != nil {
+	rep, err := r.RunReport()
+	if keepGoing {
+		r.Summarize(rep)
+	}
+	if 0 < len(junitPath) {
+		if jerr := writeJUnit(junitPath, rep); jerr != nil {
+			fmt.Printf("*-*-* Error: %s\n", jerr)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
 		fmt.Printf("*-*-* Error: %s\n", err)
 		os.Exit(1)
 	}
 }
+
+func writeJUnit(path string, rep *gtt.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = rep.WriteJUnit(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// addUseCases loads the use cases at the paths and appends them to the
+// list. A file that is already in the list, such as a use case of a suite
+// that is also given as an argument, is only added once since a use case can
+// not be run more than once at the same time.
+func addUseCases(useCases []*gtt.UseCase, paths []string) ([]*gtt.UseCase, error) {
+	seen := map[string]bool{}
+	for _, uc := range useCases {
+		seen[fileKey(uc.Filepath)] = true
+	}
+	for _, path := range paths {
+		key := fileKey(path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		uc, err := gtt.NewUseCase(path)
+		if err != nil {
+			return nil, err
+		}
+		useCases = append(useCases, uc)
+	}
+	return useCases, nil
+}
+
+func fileKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// stdout is a writer to os.Stdout that is not closed with the reporter
+// output.
+type stdout struct {
+	io.Writer
+}
+
+func (stdout) Close() error {
+	return nil
+}
+
+func openOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return stdout{Writer: os.Stdout}, nil
+	}
+	return os.Create(path)
+}
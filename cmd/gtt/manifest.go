@@ -0,0 +1,81 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ohler55/graphql-test-tool/gtt"
+	"github.com/ohler55/ojg/oj"
+)
+
+// manifest is the manifest sub-command. It writes a persisted operation
+// manifest for the operations in the use case files.
+func manifest(args []string) error {
+	format := "apollo"
+	out := "-"
+	fs := flag.NewFlagSet("manifest", flag.ExitOnError)
+	fs.StringVar(&format, "format", format, "manifest format, apollo or relay")
+	fs.StringVar(&out, "o", out, "output file, - for stdout")
+	fs.Var(&include, "include", "pattern for files to include from directories, may be repeated (default *.json and *.sen)")
+	fs.Var(&exclude, "exclude", "pattern for files and directories to exclude, may be repeated")
+	fs.StringVar(&suitePath, "suite", suitePath, "suite file with setup, use cases, and teardown")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, `
+
+usage: %s manifest [<options>] <json-file|directory|glob>...
+       %s manifest -suite <suite-file> [<options>] [<json-file|directory|glob>...]
+
+Writes a persisted operation manifest for the operations in the use case files
+and in the setup, use cases, and teardown of the suite file.
+
+`, filepath.Base(os.Args[0]), filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, "\n")
+	}
+	_ = fs.Parse(args)
+
+	paths, err := gtt.FindUseCases(fs.Args(), include, exclude)
+	if err != nil {
+		return err
+	}
+	var useCases []*gtt.UseCase
+	if 0 < len(suitePath) {
+		suite, err := gtt.NewSuite(suitePath)
+		if err != nil {
+			return err
+		}
+		if suite.Setup != nil {
+			useCases = append(useCases, suite.Setup)
+		}
+		useCases = append(useCases, suite.UseCases...)
+		if suite.Teardown != nil {
+			useCases = append(useCases, suite.Teardown)
+		}
+	}
+	if useCases, err = addUseCases(useCases, paths); err != nil {
+		return err
+	}
+	m := gtt.NewManifest(useCases)
+	var v interface{}
+	switch format {
+	case "apollo":
+		v = m.Apollo()
+	case "relay":
+		v = m.Relay()
+	default:
+		return fmt.Errorf("%s is not a valid manifest format", format)
+	}
+	w, err := openOutput(out)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, oj.JSON(v, &oj.Options{Indent: 2, Sort: true}))
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
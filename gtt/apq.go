@@ -0,0 +1,214 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ohler55/ojg/oj"
+	"github.com/ohler55/ojg/sen"
+)
+
+// APQ describes how a step uses the Automatic Persisted Queries protocol. The
+// first request includes only the sha256 hash of the query. If the server
+// responds with a PersistedQueryNotFound error the request is repeated with
+// the full query so that the server can register it. The final response is
+// checked against the Expect and Status of the step. The hash only request
+// and response are recorded in the APQ of the step result.
+type APQ struct {
+
+	// Method is the HTTP method used for both requests, either "GET" or
+	// "POST". The default is the Method of the step or "POST" if the step
+	// does not have a Method.
+	Method string
+
+	// Miss if not nil is the expected outcome of the hash only request. True
+	// indicates a PersistedQueryNotFound error is expected while false
+	// indicates the server should already have the query.
+	Miss *bool
+
+	// Status if not zero is the expected status code of the hash only
+	// request.
+	Status int
+
+	// Expect if not nil is the expected response to the hash only request
+	// using the same comparison rules as the step Expect.
+	Expect interface{}
+}
+
+// Set the members of the APQ based on the data provided. The data can be
+// true to use the defaults or a map.
+func (apq *APQ) Set(data interface{}) (err error) {
+	switch td := data.(type) {
+	case bool:
+		return nil
+	case map[string]interface{}:
+		apq.Method, _ = td["method"].(string)
+		apq.Method = strings.ToUpper(apq.Method)
+		switch apq.Method {
+		case "", "GET", "POST":
+		default:
+			return fmt.Errorf("%s is not a valid persisted query method", apq.Method)
+		}
+		if b, ok := td["miss"].(bool); ok {
+			apq.Miss = &b
+		}
+		switch n := td["status"].(type) {
+		case float64:
+			apq.Status = int(n)
+		case int64:
+			apq.Status = int(n)
+		}
+		if v := td["expect"]; v != nil {
+			if apq.Expect, err = asMapOrString(v); err != nil {
+				return
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%T is not a valid type for apq", data)
+}
+
+// Native representation of the APQ.
+func (apq *APQ) Native() interface{} {
+	native := map[string]interface{}{}
+	if 0 < len(apq.Method) {
+		native["method"] = apq.Method
+	}
+	if apq.Miss != nil {
+		native["miss"] = *apq.Miss
+	}
+	if 0 < apq.Status {
+		native["status"] = apq.Status
+	}
+	addAny(native, "expect", apq.Expect)
+
+	return native
+}
+
+// persisted executes a step using the Automatic Persisted Queries protocol.
+func (s *Step) persisted(uc *UseCase, sr *StepResult, u string, vars map[string]interface{}) error {
+	if len(s.Content) == 0 {
+		return fmt.Errorf("a persisted query step must have content in step %s", s.Label)
+	}
+	method := s.APQ.Method
+	if len(method) == 0 {
+		if method = s.Method; len(method) == 0 {
+			method = "POST"
+		}
+	}
+	sum := sha256.Sum256([]byte(s.Content))
+	ext := map[string]interface{}{}
+	for k, v := range s.Extensions {
+		ext[k] = v
+	}
+	ext["persistedQuery"] = map[string]interface{}{
+		"version":    1,
+		"sha256Hash": hex.EncodeToString(sum[:]),
+	}
+	res, body, err := s.apqSend(uc, sr, method, u, vars, ext, false)
+	if err != nil {
+		return err
+	}
+	miss := persistedQueryNotFound(body)
+	sr.APQ = &APQResult{
+		Method:     sr.Method,
+		URL:        sr.URL,
+		StatusCode: sr.StatusCode,
+		Request:    sr.Request,
+		Response:   sr.Response,
+		Miss:       miss,
+	}
+	if 0 < s.APQ.Status && s.APQ.Status != res.StatusCode {
+		return fmt.Errorf("persisted query status code mismatch. Expected %d, received %d", s.APQ.Status, res.StatusCode)
+	}
+	if s.APQ.Miss != nil && *s.APQ.Miss != miss {
+		if miss {
+			return fmt.Errorf("%s expected the persisted query to be found", s.Label)
+		}
+		return fmt.Errorf("%s expected the persisted query to not be found", s.Label)
+	}
+	if s.APQ.Expect != nil {
+		var p sen.Parser
+		var result interface{}
+		if result, err = p.Parse(body); err != nil {
+			return err
+		}
+		if err = s.compare(uc, "persisted query result", result, s.APQ.Expect, s.apqSrc, sr); err != nil {
+			return err
+		}
+	}
+	if miss {
+		if res, body, err = s.apqSend(uc, sr, method, u, vars, ext, true); err != nil {
+			return err
+		}
+	}
+	return s.checkResponse(uc, sr, res, body)
+}
+
+// apqSend sends a persisted query request with or without the query.
+func (s *Step) apqSend(
+	uc *UseCase,
+	sr *StepResult,
+	method string,
+	u string,
+	vars map[string]interface{},
+	ext map[string]interface{},
+	withQuery bool) (*http.Response, []byte, error) {
+
+	if method == "GET" {
+		params := url.Values{}
+		if withQuery {
+			params.Set("query", s.Content)
+		}
+		if 0 < len(vars) {
+			params.Set("variables", oj.JSON(vars))
+		}
+		if 0 < len(s.Op) {
+			params.Set("operationName", s.Op)
+		}
+		params.Set("extensions", oj.JSON(ext))
+
+		return s.send(uc, sr, method, addQuery(u, params), "", nil, "")
+	}
+	wrap := map[string]interface{}{"extensions": ext}
+	if withQuery {
+		wrap["query"] = s.Content
+	}
+	if 0 < len(vars) {
+		wrap["variables"] = vars
+	}
+	if 0 < len(s.Op) {
+		wrap["operationName"] = s.Op
+	}
+	j := oj.JSON(wrap)
+
+	return s.send(uc, sr, method, u, "application/json", strings.NewReader(j), uc.replaceVars(j))
+}
+
+// persistedQueryNotFound returns true if the response body includes a
+// PersistedQueryNotFound error.
+func persistedQueryNotFound(body []byte) bool {
+	v, err := oj.Parse(body)
+	if err != nil {
+		return false
+	}
+	top, _ := v.(map[string]interface{})
+	errs, _ := top["errors"].([]interface{})
+	for _, e := range errs {
+		em, _ := e.(map[string]interface{})
+		if msg, _ := em["message"].(string); msg == "PersistedQueryNotFound" {
+			return true
+		}
+		ext, _ := em["extensions"].(map[string]interface{})
+		if code, _ := ext["code"].(string); code == "PERSISTED_QUERY_NOT_FOUND" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// apqServer registers queries by hash and responds with the query. A hash
+// only request for an unknown query is answered with a
+// PersistedQueryNotFound error.
+func apqServer() *httptest.Server {
+	var mu sync.Mutex
+	queries := map[string]string{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Query      string `json:"query"`
+			Extensions struct {
+				PersistedQuery struct {
+					Hash string `json:"sha256Hash"`
+				} `json:"persistedQuery"`
+			} `json:"extensions"`
+		}
+		if r.Method == "GET" {
+			req.Query = r.URL.Query().Get("query")
+			_ = json.Unmarshal([]byte(r.URL.Query().Get("extensions")), &req.Extensions)
+		} else {
+			_ = json.NewDecoder(r.Body).Decode(&req)
+		}
+		hash := req.Extensions.PersistedQuery.Hash
+		mu.Lock()
+		defer mu.Unlock()
+		if 0 < len(req.Query) {
+			sum := sha256.Sum256([]byte(req.Query))
+			if hex.EncodeToString(sum[:]) != hash {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors":[{"message":"provided sha does not match query"}]}`))
+				return
+			}
+			queries[hash] = req.Query
+		}
+		q, has := queries[hash]
+		if !has {
+			_, _ = w.Write([]byte(`{"errors":[{"message":"PersistedQueryNotFound","extensions":{"code":"PERSISTED_QUERY_NOT_FOUND"}}]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"query": q}})
+	}))
+}
+
+func TestAPQ(t *testing.T) {
+	ts := apqServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"apq.sen": `{steps: [
+  {
+    label: miss
+    content: "{a}"
+    apq: {miss: true expect: {errors: [{message: PersistedQueryNotFound}]}}
+    expect: {data: {query: "{a}"}}
+  }
+  {label: hit content: "{a}" apq: {miss: false} expect: {data: {query: "{a}"}}}
+  {label: get content: "{b}" apq: {method: GET miss: true} expect: {data: {query: "{b}"}}}
+  {label: get-hit content: "{b}" apq: {method: GET miss: false status: 200} expect: {data: {query: "{b}"}}}
+]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "apq.sen"),
+	}
+	rep, err := r.RunReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps := rep.UseCases[0].Steps
+	for i, c := range []struct {
+		miss       bool
+		apqRequest string
+		request    string
+	}{
+		{miss: true, apqRequest: `{"extensions":`, request: `"query":"{a}"`},
+		{miss: false, apqRequest: `{"extensions":`, request: `{"extensions":`},
+		{miss: true, apqRequest: "extensions=", request: "query=%7Bb%7D"},
+		{miss: false, apqRequest: "extensions=", request: "extensions="},
+	} {
+		sr := steps[i]
+		if sr.APQ == nil {
+			t.Fatalf("%s: expected an APQ result", sr.Label)
+		}
+		if sr.APQ.Miss != c.miss {
+			t.Errorf("%s: expected miss %t", sr.Label, c.miss)
+		}
+		apqReq, req := sr.APQ.Request, sr.Request
+		if sr.APQ.Method == "GET" {
+			apqReq, req = sr.APQ.URL, sr.URL
+		}
+		if !strings.Contains(apqReq, c.apqRequest) || strings.Contains(apqReq, "query=") || strings.Contains(apqReq, `"query"`) {
+			t.Errorf("%s: expected a hash only request, got %s", sr.Label, apqReq)
+		}
+		if !strings.Contains(req, c.request) {
+			t.Errorf("%s: expected %s in the final request, got %s", sr.Label, c.request, req)
+		}
+		if c.miss && !strings.Contains(sr.APQ.Response, "PersistedQueryNotFound") {
+			t.Errorf("%s: expected a PersistedQueryNotFound response, got %s", sr.Label, sr.APQ.Response)
+		}
+		if !strings.Contains(sr.Response, `"query":`) {
+			t.Errorf("%s: expected the final response, got %s", sr.Label, sr.Response)
+		}
+	}
+	if _, has := steps[0].Native().(map[string]interface{})["apq"]; !has {
+		t.Error("expected the APQ result in the native step result")
+	}
+	var junit bytes.Buffer
+	if err = rep.WriteJUnit(&junit); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(junit.String(), "PersistedQueryNotFound") {
+		t.Errorf("expected the hash only response in the JUnit output, got %s", junit.String())
+	}
+}
+
+func TestAPQFailures(t *testing.T) {
+	ts := apqServer()
+	defer ts.Close()
+
+	for _, c := range []struct {
+		name string
+		step string
+		err  string
+	}{
+		{
+			name: "expect",
+			step: `{label: q content: "{c}" apq: {expect: {errors: [{message: Other}]}}}`,
+			err:  "persisted query result does not match expected at errors.0.message",
+		},
+		{name: "miss", step: `{label: q content: "{d}" apq: {miss: false}}`, err: "expected the persisted query to be found"},
+		{name: "status", step: `{label: q content: "{e}" apq: {status: 201}}`, err: "Expected 201, received 200"},
+		{name: "final", step: `{label: q content: "{f}" apq: true expect: {data: {query: "{g}"}}}`, err: "q result does not match"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"apq.sen": `{steps: [` + c.step + `]}`})
+			r := Runner{
+				Server:   ts.URL,
+				Writer:   ioutil.Discard,
+				UseCases: loadUseCases(t, dir, "apq.sen"),
+			}
+			rep, err := r.RunReport()
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Fatalf("expected an error with %q, got %v", c.err, err)
+			}
+			if sr := rep.UseCases[0].Steps[0]; sr.APQ == nil || !sr.APQ.Miss {
+				t.Errorf("expected a missed APQ result, got %v", sr.APQ)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+)
+
+// Operation is one operation in a batched request.
+type Operation struct {
+
+	// Content is the GraphQL query.
+	Content string
+
+	// Op is the operation name.
+	Op string
+
+	// Vars are the variables for the operation. As with a step, a string
+	// value that begins with a '$' is replaced by a remembered value.
+	Vars map[string]interface{}
+}
+
+// Set the members of the operation based on the data provided.
+func (o *Operation) Set(data interface{}) (err error) {
+	m, _ := data.(map[string]interface{})
+	if m == nil {
+		return fmt.Errorf("%T is not a valid type for a batch operation", data)
+	}
+	o.Op, _ = m["op"].(string)
+	if o.Content, err = asString(m["content"]); err != nil {
+		return
+	}
+	if len(o.Content) == 0 {
+		return fmt.Errorf("a batch operation must have content")
+	}
+	if v := m["vars"]; v != nil {
+		var ok bool
+		if o.Vars, ok = v.(map[string]interface{}); !ok {
+			return fmt.Errorf("%T is not a valid type for a map[string]interface{}", v)
+		}
+	}
+	return nil
+}
+
+// Native representation of the operation.
+func (o *Operation) Native() interface{} {
+	native := map[string]interface{}{
+		"content": easyString(o.Content),
+	}
+	if 0 < len(o.Op) {
+		native["op"] = o.Op
+	}
+	if o.Vars != nil {
+		native["vars"] = o.Vars
+	}
+	return native
+}
+
+// batchContent returns the batched operations as a list of request objects.
+func (s *Step) batchContent(uc *UseCase) ([]interface{}, error) {
+	list := make([]interface{}, 0, len(s.Batch))
+	for _, o := range s.Batch {
+		req := map[string]interface{}{}
+		if err := uc.setDocument(req, o.Content); err != nil {
+			return nil, err
+		}
+		if vars := uc.resolveVars(o.Vars); 0 < len(vars) {
+			req["variables"] = vars
+		}
+		if 0 < len(o.Op) {
+			req["operationName"] = o.Op
+		}
+		list = append(list, req)
+	}
+	return list, nil
+}
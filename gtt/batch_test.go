@@ -0,0 +1,100 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+// batchServer responds to a batch of operations with a result for each
+// operation. The echo operation returns the variables of the operation.
+func batchServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		ops, _ := oj.Parse(body)
+		list, ok := ops.([]interface{})
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":[{"message":"not a batch"}]}`))
+			return
+		}
+		results := make([]interface{}, 0, len(list))
+		for _, op := range list {
+			m, _ := op.(map[string]interface{})
+			query, _ := m["query"].(string)
+			var data interface{}
+			switch {
+			case strings.Contains(query, "{n}"):
+				data = map[string]interface{}{"n": 1}
+			case strings.Contains(query, "{items}"):
+				data = map[string]interface{}{"items": []interface{}{
+					map[string]interface{}{"id": "a"},
+					map[string]interface{}{"id": "b"},
+				}}
+			default:
+				data = map[string]interface{}{"echo": m["variables"]}
+			}
+			results = append(results, map[string]interface{}{"data": data})
+		}
+		_, _ = w.Write([]byte(oj.JSON(results)))
+	}))
+}
+
+func TestBatchRemember(t *testing.T) {
+	ts := batchServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"batch.sen": `{steps: [
+  {
+    label: batch
+    batch: [
+      {content: "query A {n}" op: A}
+      {content: "query B {items}" op: B}
+    ]
+    expect: [{data: {n: 1}} {data: {items: [{id: a} {id: b}]}}]
+    remember: {
+      n: "0.data.n"
+      second: "1.data.items.1.id"
+      item: "1.data.items.0"
+      first: "$[1].data.items[0].id"
+      missing: "1.data.items.5.id"
+    }
+  }
+  {
+    label: echo
+    batch: [{content: "query C {echo}" vars: {id: "$second" n: "$n"}}]
+    expect: [{data: {echo: {id: b n: 1}}}]
+  }
+]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "batch.sen"),
+	}
+	rep, err := r.RunReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := rep.UseCases[0].Memory
+	for k, expect := range map[string]interface{}{
+		"n":      int64(1),
+		"second": "b",
+		"item":   map[string]interface{}{"id": "a"},
+		"first":  "a",
+	} {
+		if mm := match(memory[k], expect); 0 < len(mm) {
+			t.Errorf("expected %s to be remembered as %v, got %v", k, expect, memory[k])
+		}
+	}
+	if v, has := memory["missing"]; has {
+		t.Errorf("expected nothing remembered for an index out of range, got %v", v)
+	}
+}
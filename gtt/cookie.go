@@ -0,0 +1,229 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// cookiePrefix is the prefix of a Remember path that identifies a cookie.
+const cookiePrefix = "cookie:"
+
+// Cookies describes changes to the cookie jar of a use case made before the
+// request of a step.
+type Cookies struct {
+
+	// Clear if true removes all cookies from the jar. If the runner shares
+	// cookies the shared jar is cleared for all use cases. The jar of the
+	// runner Client can not be cleared and results in an error.
+	Clear bool
+
+	// Add are cookies to add to the jar. The values can be a remembered
+	// value by using a string that starts with a '$'.
+	Add map[string]string
+}
+
+// Set the members of the cookies based on the data provided.
+func (c *Cookies) Set(data interface{}) (err error) {
+	m, _ := data.(map[string]interface{})
+	if m == nil {
+		return fmt.Errorf("%T is not a valid type for cookies", data)
+	}
+	c.Clear, _ = m["clear"].(bool)
+	if v := m["add"]; v != nil {
+		if c.Add, err = asMapStrStr(v); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// Native representation of the cookies.
+func (c *Cookies) Native() interface{} {
+	native := map[string]interface{}{}
+	if c.Clear {
+		native["clear"] = true
+	}
+	if c.Add != nil {
+		native["add"] = c.Add
+	}
+	return native
+}
+
+func newJar() http.CookieJar {
+	// An error is only returned if the options are invalid.
+	jar, _ := cookiejar.New(nil)
+	return jar
+}
+
+// sharedJar is the cookie jar shared by all the use cases of a runner. It
+// can be cleared in place so that clearing the cookies in one use case
+// clears them for every use case.
+type sharedJar struct {
+	mu  sync.Mutex
+	jar http.CookieJar
+}
+
+func (j *sharedJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	jar := j.jar
+	j.mu.Unlock()
+	jar.SetCookies(u, cookies)
+}
+
+func (j *sharedJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	jar := j.jar
+	j.mu.Unlock()
+	return jar.Cookies(u)
+}
+
+func (j *sharedJar) clear() {
+	j.mu.Lock()
+	j.jar = newJar()
+	j.mu.Unlock()
+}
+
+// clearCookies removes all the cookies from the jar in use by the use case.
+// An http.CookieJar can not list the cookies it holds so the jar of the
+// runner Client, which is owned by the caller, can not be cleared.
+func (uc *UseCase) clearCookies() error {
+	if r := uc.runner; r != nil && r.Client != nil && r.Client.Jar != nil {
+		return fmt.Errorf("the cookie jar of the runner client can not be cleared")
+	}
+	if sj, ok := uc.jar.(*sharedJar); ok {
+		sj.clear()
+		return nil
+	}
+	uc.jar = newJar()
+
+	return nil
+}
+
+// cookies returns the cookies in the jar for the URL. WebSocket URLs are
+// treated as the equivalent HTTP URLs.
+func (uc *UseCase) cookies(rawURL string) []*http.Cookie {
+	u, err := cookieURL(rawURL)
+	if err != nil || uc.jar == nil {
+		return nil
+	}
+	return uc.jar.Cookies(u)
+}
+
+// addCookies adds the cookies for the URL to the header.
+func (uc *UseCase) addCookies(header http.Header, rawURL string) {
+	cookies := uc.cookies(rawURL)
+	if len(cookies) == 0 {
+		return
+	}
+	pairs := make([]string, 0, len(cookies))
+	for _, c := range cookies {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	header.Set("Cookie", strings.Join(pairs, "; "))
+}
+
+func cookieURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	}
+	return u, nil
+}
+
+// apply the cookie changes to the jar of the use case.
+func (c *Cookies) apply(uc *UseCase, rawURL string) error {
+	switch {
+	case uc.jar == nil:
+		uc.jar = newJar()
+	case c.Clear:
+		if err := uc.clearCookies(); err != nil {
+			return err
+		}
+	}
+	if len(c.Add) == 0 {
+		return nil
+	}
+	u, err := cookieURL(rawURL)
+	if err != nil {
+		return err
+	}
+	cookies := make([]*http.Cookie, 0, len(c.Add))
+	for name, value := range c.Add {
+		if 0 < len(value) && value[0] == '$' {
+			value = fmt.Sprintf("%v", uc.memory[value[1:]])
+		}
+		cookies = append(cookies, &http.Cookie{Name: name, Value: value, Path: "/"})
+	}
+	uc.jar.SetCookies(u, cookies)
+
+	return nil
+}
+
+// checkCookies checks the cookies in the jar for the URL against the
+// ExpectCookies of the step. A nil expected value indicates the cookie must
+// not be present.
+func (s *Step) checkCookies(uc *UseCase, rawURL string) error {
+	if len(s.ExpectCookies) == 0 {
+		return nil
+	}
+	values := map[string]string{}
+	for _, c := range uc.cookies(rawURL) {
+		values[c.Name] = c.Value
+	}
+	names := make([]string, 0, len(s.ExpectCookies))
+	for name := range s.ExpectCookies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expect := s.ExpectCookies[name]
+		value, has := values[name]
+		switch {
+		case expect == nil && has:
+			return s.cookieError(name, value, "absent")
+		case expect == nil:
+		case !has:
+			return s.cookieError(name, "absent", expect)
+		case !matchString(value, expect):
+			return s.cookieError(name, value, expect)
+		}
+	}
+	return nil
+}
+
+func (s *Step) cookieError(name string, actual, expect interface{}) error {
+	if pos := s.cookiesSrc.get(name); pos != nil {
+		return fmt.Errorf("%s cookie %s does not match expected (%s). %v != %v", s.Label, name, pos.pos, actual, expect)
+	}
+	return fmt.Errorf("%s cookie %s does not match expected. %v != %v", s.Label, name, actual, expect)
+}
+
+// rememberCookies remembers the cookie values identified by Remember paths
+// that start with "cookie:".
+func (s *Step) rememberCookies(uc *UseCase, rawURL string) {
+	for k, path := range s.Remember {
+		if !strings.HasPrefix(path, cookiePrefix) {
+			continue
+		}
+		name := strings.TrimSpace(path[len(cookiePrefix):])
+		for _, c := range uc.cookies(rawURL) {
+			if c.Name == name {
+				uc.memory[k] = c.Value
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func cookieServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		_, _ = w.Write([]byte(`{"data":{"login":true}}`))
+	})
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		var session string
+		if c, err := r.Cookie("session"); err == nil {
+			session = c.Value
+		}
+		fmt.Fprintf(w, `{"data":{"session":%q}}`, session)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCookiesClearShared(t *testing.T) {
+	ts := cookieServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"login.sen": `{steps: [
+  {label: login path: "/login" content: "mutation {login}" expectCookies: {session: s1}}
+  {label: clear cookies: {clear: true} expectCookies: {session: null}}
+]}`,
+		"after.sen": `{steps: [
+  {label: after content: "{me}" expect: {data: {session: ""}} expectCookies: {session: null}}
+]}`,
+	})
+	r := Runner{
+		Server:       ts.URL,
+		Base:         "/graphql",
+		ShareCookies: true,
+		Writer:       ioutil.Discard,
+		UseCases:     loadUseCases(t, dir, "login.sen", "after.sen"),
+	}
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCookiesShared(t *testing.T) {
+	ts := cookieServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"login.sen": `{steps: [{label: login path: "/login" content: "mutation {login}"}]}`,
+		"after.sen": `{steps: [{label: after content: "{me}" expect: {data: {session: s1}} expectCookies: {session: s1}}]}`,
+	})
+	for _, share := range []bool{true, false} {
+		r := Runner{
+			Server:       ts.URL,
+			Base:         "/graphql",
+			ShareCookies: share,
+			Writer:       ioutil.Discard,
+			UseCases:     loadUseCases(t, dir, "login.sen", "after.sen"),
+		}
+		if err := r.Run(); (err == nil) != share {
+			t.Errorf("share %t: unexpected result %v", share, err)
+		}
+	}
+}
+
+func TestCookiesClientJar(t *testing.T) {
+	ts := cookieServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"login.sen": `{steps: [
+  {label: login path: "/login" content: "mutation {login}" expectCookies: {session: s1} remember: {sid: "cookie:session"}}
+  {label: me content: "{me}" expect: {data: {session: s1}}}
+]}`,
+	})
+	jar, _ := cookiejar.New(nil)
+	r := Runner{
+		Server:   ts.URL,
+		Base:     "/graphql",
+		Client:   &http.Client{Jar: jar},
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "login.sen"),
+	}
+	rep, err := r.RunReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sid := rep.UseCases[0].Memory["sid"]; sid != "s1" {
+		t.Errorf("expected the session cookie to be remembered, got %v", sid)
+	}
+	u, _ := url.Parse(ts.URL)
+	if cookies := jar.Cookies(u); len(cookies) != 1 || cookies[0].Value != "s1" {
+		t.Errorf("expected the session cookie in the client jar, got %v", cookies)
+	}
+}
+
+func TestCookiesClearClientJar(t *testing.T) {
+	ts := cookieServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"login.sen": `{steps: [
+  {label: login path: "/login" content: "mutation {login}"}
+  {label: clear cookies: {clear: true}}
+]}`,
+	})
+	jar, _ := cookiejar.New(nil)
+	r := Runner{
+		Server:   ts.URL,
+		Base:     "/graphql",
+		Client:   &http.Client{Jar: jar},
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "login.sen"),
+	}
+	if err := r.Run(); err == nil || !strings.Contains(err.Error(), "can not be cleared") {
+		t.Errorf("expected a clear error, got %v", err)
+	}
+	u, _ := url.Parse(ts.URL)
+	if cookies := jar.Cookies(u); len(cookies) != 1 || cookies[0].Value != "s1" {
+		t.Errorf("expected the session cookie to remain in the client jar, got %v", cookies)
+	}
+}
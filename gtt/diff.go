@@ -0,0 +1,136 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+// differ builds a unified style diff of an expected value against an actual
+// value from the mismatches found by match. The expected value tree is
+// followed and lines that match are prefixed with spaces while a mismatch is
+// written as a '-' line for the expected value and a '+' line for the actual
+// value. Only the elements present in the expected value are included
+// except for unexpected actual values reported as mismatches such as extra
+// array elements or extra map members when the "*" key is used in an
+// expected map. Those are written as '+' lines.
+type differ struct {
+	b          strings.Builder
+	mismatches map[string]*Mismatch
+}
+
+// diff returns a unified diff of the expected and actual values given the
+// mismatches returned by match for the same values.
+func diff(expect, actual interface{}, mismatches []*Mismatch) string {
+	d := differ{mismatches: map[string]*Mismatch{}}
+	for _, m := range mismatches {
+		d.mismatches[locKey(m.loc)] = m
+	}
+	d.value(0, "", nil, expect, actual, true)
+	return d.b.String()
+}
+
+func (d *differ) value(depth int, key string, loc []interface{}, expect, actual interface{}, present bool) {
+	if d.mismatched(loc) {
+		d.line('-', depth, key, diffString(expect))
+		if present {
+			d.line('+', depth, key, diffString(actual))
+		}
+		return
+	}
+	switch x := expect.(type) {
+	case map[string]interface{}:
+		am, _ := actual.(map[string]interface{})
+		d.line(' ', depth, key, "{")
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			if k != "*" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			av, has := am[k]
+			d.value(depth+1, k, appendLoc(loc, k), x[k], av, has)
+		}
+		extra := make([]string, 0, len(am))
+		for k := range am {
+			if _, has := x[k]; !has && d.mismatched(appendLoc(loc, k)) {
+				extra = append(extra, k)
+			}
+		}
+		sort.Strings(extra)
+		for _, k := range extra {
+			d.line('+', depth+1, k, diffString(am[k]))
+		}
+		d.line(' ', depth, "", "}")
+	case []interface{}:
+		aa, _ := actual.([]interface{})
+		d.line(' ', depth, key, "[")
+		for i, xv := range x {
+			if i < len(aa) {
+				d.value(depth+1, "", appendLoc(loc, i), xv, aa[i], true)
+			} else {
+				d.value(depth+1, "", appendLoc(loc, i), xv, nil, false)
+			}
+		}
+		for i := len(x); i < len(aa); i++ {
+			if d.mismatched(appendLoc(loc, i)) {
+				d.line('+', depth+1, "", diffString(aa[i]))
+			}
+		}
+		d.line(' ', depth, "", "]")
+	default:
+		// A missing member matches an expected null.
+		if present {
+			d.line(' ', depth, key, diffString(actual))
+		} else {
+			d.line(' ', depth, key, diffString(expect))
+		}
+	}
+}
+
+func (d *differ) mismatched(loc []interface{}) bool {
+	_, has := d.mismatches[locKey(loc)]
+	return has
+}
+
+func (d *differ) line(mark byte, depth int, key string, value string) {
+	d.b.WriteByte(mark)
+	d.b.WriteByte(' ')
+	d.b.WriteString(strings.Repeat("  ", depth))
+	if 0 < len(key) {
+		d.b.WriteString(strconv.Quote(key))
+		d.b.WriteString(": ")
+	}
+	d.b.WriteString(value)
+	d.b.WriteByte('\n')
+}
+
+// colorDiff returns the diff with the '-' lines in red and the '+' lines in
+// green.
+func colorDiff(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "-"):
+			lines[i] = red + line + normal
+		case strings.HasPrefix(line, "+"):
+			lines[i] = green + line + normal
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// locKey returns a map key for a location in a value.
+func locKey(loc []interface{}) string {
+	return oj.JSON(loc)
+}
+
+func diffString(v interface{}) string {
+	return oj.JSON(v, &oj.Options{Sort: true})
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ohler55/ojg/sen"
+)
+
+func TestDiff(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		expect string
+		actual string
+		diff   string
+	}{
+		{
+			name:   "match",
+			expect: `{a: 1 b: [x y]}`,
+			actual: `{a: 1 b: [x y] c: 3}`,
+			diff: `  {
+    "a": 1
+    "b": [
+      "x"
+      "y"
+    ]
+  }
+`,
+		},
+		{
+			name:   "missing null",
+			expect: `{a: 1 gone: null}`,
+			actual: `{a: 1}`,
+			diff: `  {
+    "a": 1
+    "gone": null
+  }
+`,
+		},
+		{
+			name:   "value",
+			expect: `{a: 1 b: {c: 2}}`,
+			actual: `{a: 1 b: {c: 3}}`,
+			diff: `  {
+    "a": 1
+    "b": {
+-     "c": 2
++     "c": 3
+    }
+  }
+`,
+		},
+		{
+			name:   "missing",
+			expect: `{a: 1 b: 2}`,
+			actual: `{a: 1}`,
+			diff: `  {
+    "a": 1
+-   "b": 2
+  }
+`,
+		},
+		{
+			name:   "type",
+			expect: `{a: {b: 1}}`,
+			actual: `{a: [1]}`,
+			diff: `  {
+-   "a": {"b":1}
++   "a": [1]
+  }
+`,
+		},
+		{
+			name:   "exact",
+			expect: `{a: 1 "*": null}`,
+			actual: `{a: 1 b: 2 c: null}`,
+			diff: `  {
+    "a": 1
++   "b": 2
+  }
+`,
+		},
+		{
+			name:   "array",
+			expect: `[1 2 3]`,
+			actual: `[1 4]`,
+			diff: `  [
+    1
+-   2
++   4
+-   3
+  ]
+`,
+		},
+		{
+			name:   "array extra",
+			expect: `[1]`,
+			actual: `[1 2]`,
+			diff: `  [
+    1
++   2
+  ]
+`,
+		},
+		{
+			name:   "regex",
+			expect: `{a: "/^ab/"}`,
+			actual: `{a: abc}`,
+			diff: `  {
+    "a": "abc"
+  }
+`,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			expect := sen.MustParse([]byte(c.expect))
+			actual := sen.MustParse([]byte(c.actual))
+			if d := diff(expect, actual, match(actual, expect)); d != c.diff {
+				t.Errorf("expected\n%s\ngot\n%s", c.diff, d)
+			}
+		})
+	}
+}
+
+func TestColorDiff(t *testing.T) {
+	if s := colorDiff("  [\n-   1\n+   2\n  ]"); s != "  [\n"+red+"-   1"+normal+"\n"+green+"+   2"+normal+"\n  ]" {
+		t.Errorf("unexpected color diff %q", s)
+	}
+}
+
+func TestDiffLogged(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"case.sen": `{steps: [
+  {label: query content: "{a}" expect: {data: {a: 2}}}
+]}`,
+	})
+	var out bytes.Buffer
+	r := Runner{
+		Server:   ts.URL,
+		NoColor:  true,
+		Writer:   &out,
+		UseCases: loadUseCases(t, dir, "case.sen"),
+	}
+	if err := r.Run(); err == nil {
+		t.Fatal("expected a mismatch")
+	}
+	header := filepath.Join(dir, "case.sen") + ":2:3 query result diff:\n"
+	if !strings.Contains(out.String(), header) {
+		t.Errorf("expected the diff to start with %q, got %s", header, out.String())
+	}
+}
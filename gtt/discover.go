@@ -0,0 +1,269 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultInclude is the include pattern used by FindUseCases when no include
+// patterns are provided.
+var DefaultInclude = []string{"*.json", "*.sen"}
+
+// FindUseCases returns the paths of the use case files identified by the
+// args. An arg can be a file, a directory, or a glob pattern. Files are
+// always returned. Directories are walked recursively and the files that
+// match one of the include patterns are returned. Glob patterns follow the
+// rules of path.Match with the addition of a "**" path element that matches
+// zero or more directories. Files and directories found in a directory or
+// by a glob pattern that match one of the exclude patterns are skipped as
+// are files that contain an array, such as step include files, since a use
+// case is always a map.
+//
+// An include or exclude pattern without a '/' is matched against the base
+// name of a file or directory while a pattern with a '/' is matched against
+// the full path and may include "**" elements.
+//
+// The files found for each arg are sorted and the results for each arg are
+// returned in the order of the args with duplicates removed.
+func FindUseCases(args []string, include []string, exclude []string) ([]string, error) {
+	if len(include) == 0 {
+		include = DefaultInclude
+	}
+	for _, pat := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pat, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s. %s", pat, err)
+		}
+	}
+	var paths []string
+	seen := map[string]bool{}
+	for _, arg := range args {
+		var found []string
+		var err error
+		if hasMeta(arg) {
+			found, err = globFiles(nil, arg, exclude)
+		} else {
+			var fi fs.FileInfo
+			if fi, err = os.Stat(arg); err != nil {
+				return nil, err
+			}
+			if fi.IsDir() {
+				found, err = walkFiles(nil, arg, "", nil, include, exclude)
+			} else {
+				found = []string{arg}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no use case files found for %s", arg)
+		}
+		sort.Strings(found)
+		for _, p := range found {
+			clean := filepath.Clean(p)
+			if !seen[clean] {
+				seen[clean] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// globFiles returns the files that match a glob pattern. Only the directory
+// below the leading elements of the pattern without meta characters is
+// walked. If fsys is nil the files are from the operating system file
+// system.
+func globFiles(fsys fs.FS, pattern string, exclude []string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %s. %s", pattern, err)
+	}
+	slashed := filepath.ToSlash(pattern)
+	elements := strings.Split(slashed, "/")
+	i := 0
+	for ; i < len(elements)-1 && !hasMeta(elements[i]); i++ {
+	}
+	root := filepath.FromSlash(strings.Join(elements[:i], "/"))
+	switch {
+	case 0 < len(root):
+	case strings.HasPrefix(slashed, "/"):
+		root = string(filepath.Separator)
+	default:
+		root = "."
+	}
+	var err error
+	if fsys == nil {
+		_, err = os.Stat(root)
+	} else {
+		_, err = fs.Stat(fsys, root)
+	}
+	if err != nil {
+		return nil, nil
+	}
+	return walkFiles(fsys, root, root, elements[i:], nil, exclude)
+}
+
+// walkFiles walks the directory at root and returns the files that match the
+// elements relative to base if elements is not empty or one of the include
+// patterns otherwise. If fsys is nil the operating system file system is
+// walked.
+func walkFiles(fsys fs.FS, root string, base string, elements []string, include []string, exclude []string) ([]string, error) {
+	var found []string
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && matchAny(exclude, p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if 0 < len(elements) {
+			rel, err := relPath(fsys, base, p)
+			if err != nil {
+				return err
+			}
+			relElements := strings.Split(filepath.ToSlash(rel), "/")
+			switch {
+			case d.IsDir():
+				if p != root && !matchDir(elements, relElements) {
+					return filepath.SkipDir
+				}
+			case d.Type().IsRegular() && matchElements(elements, relElements) && !isStepList(fsys, p):
+				found = append(found, p)
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		if matchAny(include, p) && !isStepList(fsys, p) {
+			found = append(found, p)
+		}
+		return nil
+	}
+	var err error
+	if fsys == nil {
+		err = filepath.WalkDir(root, walk)
+	} else {
+		err = fs.WalkDir(fsys, root, walk)
+	}
+	return found, err
+}
+
+// relPath returns the path p relative to base. Paths in an fs.FS are always
+// slash separated and do not include "." or ".." elements.
+func relPath(fsys fs.FS, base string, p string) (string, error) {
+	if fsys == nil {
+		return filepath.Rel(base, p)
+	}
+	if base == "." {
+		return p, nil
+	}
+	return strings.TrimPrefix(p, base+"/"), nil
+}
+
+// isStepList returns true if the first value in the file is an array as is
+// the case for step include files. Leading white space, a byte order mark,
+// and comments are skipped. Files that can not be read are not considered a
+// list so that the error is reported when the file is loaded.
+func isStepList(fsys fs.FS, filePath string) bool {
+	var data []byte
+	var err error
+	if fsys == nil {
+		data, err = ioutil.ReadFile(filePath)
+	} else {
+		data, err = fs.ReadFile(fsys, filePath)
+	}
+	if err != nil {
+		return false
+	}
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r', ',':
+		case '/':
+			if i+1 < len(data) && data[i+1] == '/' {
+				for i < len(data) && data[i] != '\n' {
+					i++
+				}
+				continue
+			}
+			return false
+		default:
+			return data[i] == '['
+		}
+	}
+	return false
+}
+
+// matchAny returns true if the file path matches any of the patterns.
+func matchAny(patterns []string, filePath string) bool {
+	slashed := filepath.ToSlash(filepath.Clean(filePath))
+	for _, pat := range patterns {
+		if strings.ContainsRune(pat, '/') {
+			if matchElements(strings.Split(path.Clean(pat), "/"), strings.Split(slashed, "/")) {
+				return true
+			}
+		} else if ok, _ := path.Match(pat, path.Base(slashed)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchElements matches path elements against pattern elements where a "**"
+// pattern element matches zero or more path elements.
+func matchElements(pat []string, elements []string) bool {
+	for 0 < len(pat) {
+		if pat[0] == "**" {
+			for i := 0; i <= len(elements); i++ {
+				if matchElements(pat[1:], elements[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elements) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], elements[0]); !ok {
+			return false
+		}
+		pat = pat[1:]
+		elements = elements[1:]
+	}
+	return len(elements) == 0
+}
+
+// matchDir returns true if files in the directory identified by the path
+// elements could match the pattern elements.
+func matchDir(pat []string, elements []string) bool {
+	for _, e := range elements {
+		if len(pat) == 0 {
+			return false
+		}
+		if pat[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pat[0], e); !ok {
+			return false
+		}
+		pat = pat[1:]
+	}
+	return 0 < len(pat)
+}
+
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
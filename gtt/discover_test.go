@@ -0,0 +1,138 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchElements(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		path    string
+		expect  bool
+	}{
+		{pattern: "a/*.json", path: "a/b.json", expect: true},
+		{pattern: "a/*.json", path: "a/c/b.json", expect: false},
+		{pattern: "**/*.json", path: "b.json", expect: true},
+		{pattern: "**/*.json", path: "a/c/b.json", expect: true},
+		{pattern: "a/**", path: "a/c/b.json", expect: true},
+		{pattern: "a/**/b.json", path: "a/b.json", expect: true},
+		{pattern: "a/**/b.json", path: "a/x/y/b.json", expect: true},
+		{pattern: "a/**/b.json", path: "x/a/b.json", expect: false},
+		{pattern: "**/inc/**", path: "a/inc/b.json", expect: true},
+		{pattern: "a/?.json", path: "a/bc.json", expect: false},
+	} {
+		if ok := matchElements(strings.Split(c.pattern, "/"), strings.Split(c.path, "/")); ok != c.expect {
+			t.Errorf("%s %s: expected %t", c.pattern, c.path, c.expect)
+		}
+	}
+}
+
+func TestFindUseCases(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.json":          "{}",
+		"b.sen":           "{}",
+		"c.txt":           "{}",
+		"inc/steps.json":  "\xEF\xBB\xBF\n[ // included steps\n{label: inc content: \"{a}\"}]",
+		"inc/more.sen":    "\n  [\"steps.json\"]",
+		"sub/d.json":      "{}",
+		"sub/skip.json":   "{}",
+		"sub/deep/e.json": "{}",
+	})
+	for _, c := range []struct {
+		name    string
+		args    []string
+		include []string
+		exclude []string
+		expect  string
+		err     string
+	}{
+		{name: "dir", args: []string{"."}, expect: "a.json b.sen sub/d.json sub/deep/e.json sub/skip.json"},
+		{name: "include", args: []string{"."}, include: []string{"*.txt"}, expect: "c.txt"},
+		{name: "exclude", args: []string{"."}, exclude: []string{"inc", "skip.json"}, expect: "a.json b.sen sub/d.json sub/deep/e.json"},
+		{name: "exclude path", args: []string{"."}, exclude: []string{"**/deep/*.json", "*.sen"}, expect: "a.json sub/d.json sub/skip.json"},
+		{name: "file", args: []string{"c.txt"}, expect: "c.txt"},
+		{name: "include file", args: []string{"inc/steps.json"}, expect: "inc/steps.json"},
+		{name: "include dir", args: []string{"inc"}, err: "no use case files found"},
+		{name: "glob", args: []string{"sub/*.json"}, expect: "sub/d.json sub/skip.json"},
+		{name: "glob any", args: []string{"**/*.json"}, expect: "a.json sub/d.json sub/deep/e.json sub/skip.json"},
+		{name: "glob below", args: []string{"sub/**/*.json"}, expect: "sub/d.json sub/deep/e.json sub/skip.json"},
+		{name: "glob dir", args: []string{"*/deep/*.json"}, expect: "sub/deep/e.json"},
+		{name: "glob exclude", args: []string{"**/*.json"}, exclude: []string{"sub"}, expect: "a.json"},
+		{name: "order", args: []string{"sub/d.json", "."}, exclude: []string{"inc"}, expect: "sub/d.json a.json b.sen sub/deep/e.json sub/skip.json"},
+		{name: "no match", args: []string{"*.xml"}, err: "no use case files found"},
+		{name: "missing", args: []string{"x.json"}, err: "x.json"},
+		{name: "invalid", args: []string{"."}, include: []string{"["}, err: "invalid pattern"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			args := make([]string, len(c.args))
+			for i, arg := range c.args {
+				args[i] = filepath.Join(dir, arg)
+			}
+			paths, err := FindUseCases(args, c.include, c.exclude)
+			if 0 < len(c.err) {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected an error with %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, p := range paths {
+				p, _ = filepath.Rel(dir, p)
+				paths[i] = filepath.ToSlash(p)
+			}
+			if found := strings.Join(paths, " "); found != c.expect {
+				t.Errorf("expected %s, got %s", c.expect, found)
+			}
+		})
+	}
+}
+
+func TestIsStepList(t *testing.T) {
+	for _, c := range []struct {
+		data   string
+		expect bool
+	}{
+		{data: "[]", expect: true},
+		{data: "\xEF\xBB\xBF\n\t [{}]", expect: true},
+		{data: "// steps\n// more\n[{}]", expect: true},
+		{data: "{steps: []}", expect: false},
+		{data: "// [\n{}", expect: false},
+		{data: "/ [", expect: false},
+		{data: "", expect: false},
+	} {
+		dir := writeFiles(t, map[string]string{"f.sen": c.data})
+		if ok := isStepList(nil, filepath.Join(dir, "f.sen")); ok != c.expect {
+			t.Errorf("%q: expected %t", c.data, c.expect)
+		}
+	}
+	if isStepList(nil, "not-a-file.sen") {
+		t.Error("expected false for a missing file")
+	}
+}
+
+func TestFindUseCasesWithIncludes(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"case.sen":      `{steps: ["inc/steps.sen" {label: last content: "{a}"}]}`,
+		"inc/steps.sen": "[\n  // steps included by case.sen\n  {label: first content: \"{a}\"}\n]",
+	})
+	paths, err := FindUseCases([]string{dir}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || filepath.Base(paths[0]) != "case.sen" {
+		t.Fatalf("expected only case.sen, got %v", paths)
+	}
+	uc, err := NewUseCase(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uc.Steps) != 2 {
+		t.Errorf("expected 2 steps, got %d", len(uc.Steps))
+	}
+}
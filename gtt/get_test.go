@@ -0,0 +1,113 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// getServer echoes the method, the path, and the decoded URL query
+// parameters. The variables and extensions parameters are decoded as JSON.
+func getServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := map[string]interface{}{}
+		for k, v := range r.URL.Query() {
+			var jv interface{}
+			if (k == "variables" || k == "extensions") && json.Unmarshal([]byte(v[0]), &jv) == nil {
+				params[k] = jv
+			} else {
+				params[k] = v[0]
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"method": r.Method, "path": r.URL.Path, "params": params},
+		})
+	}))
+}
+
+func TestGetEncoded(t *testing.T) {
+	ts := getServer()
+	defer ts.Close()
+
+	const query = `query Song($title: String) { song(title: $title, tag: "Rock & Roll #1") { name } }`
+	dir := writeFiles(t, map[string]string{
+		"get.sen": `{steps: [
+  {
+    label: get
+    path: "/graphql?x=1"
+    method: GET
+    content: "` + strings.ReplaceAll(query, `"`, `\"`) + `"
+    op: Song
+    vars: {title: "a b&c=d#e ü+"}
+    extensions: {trace: true}
+    remember: {title: "data.params.variables.title"}
+    expect: {data: {
+      method: GET
+      path: "/graphql"
+      params: {
+        x: "1"
+        query: "` + strings.ReplaceAll(query, `"`, `\"`) + `"
+        operationName: Song
+        variables: {title: "a b&c=d#e ü+"}
+        extensions: {trace: true}
+      }
+    }}
+  }
+  {
+    label: remembered
+    method: GET
+    content: "{a}"
+    vars: {title: "$title"}
+    expect: {data: {method: GET params: {query: "{a}" variables: {title: "a b&c=d#e ü+"} "*": true}}}
+  }
+  {
+    label: post
+    content: "{a}"
+    op: "A&B"
+    vars: {q: "x&y=z"}
+    expect: {data: {method: POST params: {operationName: "A&B" variables: {q: "x&y=z"}}}}
+  }
+]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "get.sen"),
+	}
+	rep, err := r.RunReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := rep.UseCases[0].Steps[0].URL
+	if !strings.HasPrefix(u, ts.URL+"/graphql?x=1&") || strings.ContainsAny(u[len(ts.URL)+len("/graphql?x=1&"):], " #\"{}") {
+		t.Errorf("expected an encoded URL, got %s", u)
+	}
+}
+
+func TestGetManifest(t *testing.T) {
+	ts := getServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"get.sen": `{steps: [{
+  label: get
+  method: GET
+  content: "{ a }"
+  expect: {data: {method: GET params: {documentId: "` + queryHash(NormalizeQuery("{ a }")) + `" "*": true}}}
+}]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "get.sen"),
+	}
+	r.Manifest = NewManifest(r.UseCases)
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -115,10 +115,73 @@ The "steps" array contains Step objects that can include the following fields:
  - always if included and true indicates the step should always be executed
    even if a previous step has failed.
 
+ - subscribe makes the step a GraphQL subscription over a WebSocket or
+   Server-Sent Events. It can be true or an object with an optional protocol
+   ("graphql-transport-ws", the legacy "graphql-ws", "graphql-sse", or
+   "graphql-sse-single"), init payload for the connection_init message, and a
+   count of the events to collect. The events received are matched against
+   an expect array. If a name is included the subscription is run in the
+   background and the step completes once the subscription is established.
+
+ - await is the name of a background subscription. The step waits for the
+   subscription to complete or for the step timeout and then matches the
+   events collected against the expect array.
+
+ - incremental if true requests incremental delivery of @defer and @stream
+   results. A multipart/mixed response is merged into a single result that
+   is matched against the expect value.
+
+ - patches is the expected list of patches of an incremental delivery
+   response in the order received.
+
+ - files are the files to upload with a GraphQL multipart request. The keys
+   are variable paths such as "variables.file" and the values are either a
+   path relative to the use case file or an object with a path or content
+   and an optional name and type.
+
+ - batch is a list of operations, each with content and optional vars and
+   op, sent as a JSON array in one request. The expect value should be an
+   array with an element for each operation.
+
+ - expectHeaders are the expected response headers. The values follow the
+   expect rules with numbers and booleans compared as strings. An array
+   matches each value of a multi-valued header in order. A null value
+   indicates the header must not be present.
+   A remember path of "header:" followed by a header name remembers a
+   response header value.
+
+ - expectCookies are the expected cookies in the use case cookie jar after
+   the request. A null value indicates the cookie must not be present. A
+   remember path of "cookie:" followed by a cookie name remembers a cookie
+   value.
+
+ - cookies changes the cookie jar before the request. The clear flag removes
+   all cookies and the add map adds cookies. If the step has no content,
+   path, or batch no request is made.
+
+ - method if "GET" sends the content as the query URL parameter along with
+   the variables, operationName, and extensions, all URL encoded.
+
+ - extensions are the request extensions. They are included in JSON
+   requests and in GET URL parameters.
+
+ - apq uses the Automatic Persisted Queries protocol. It can be true or an
+   object with an optional method ("GET" or "POST"), miss flag, status, and
+   expect value that are checked against the hash only response. The full
+   query is sent if the server does not have it.
+
 The "steps" array can also contain strings. A string element is an include in
 that it should be a filepath relative to the original that is to a file to
 include in the steps. The include JSON file must be an array that includes
-either steps or additional includes.
+either steps or additional includes. Use cases loaded with NewUseCaseFS read
+includes and uploads from the same fs.FS, such as an embed.FS.
+
+A suite file groups use cases with shared setup and teardown steps. It is an
+object with a "comment", "setup", "useCases", "exclude", and "teardown". The
+setup steps are run once before the use cases and the values they remember are
+available to every use case. The useCases are files, directories, or glob
+patterns relative to the suite file. The teardown steps are always run after
+the use cases. Suites are loaded with NewSuite.
 
 */
 package gtt
@@ -0,0 +1,131 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// handlerServer is the default Server URL used when a Runner has a Handler
+// and no Server.
+const handlerServer = "http://handler.gtt"
+
+// pipeListener is an in-memory net.Listener. Connections are created with
+// net.Pipe so there is no network port but the full HTTP protocol is used.
+// That allows handlers to stream responses and hijack connections for
+// WebSockets.
+type pipeListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "pipe" }
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// Accept waits for and returns the next connection.
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close the listener.
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+// Addr returns the listener address.
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+// dial opens a connection to the listener. The network and address are
+// ignored.
+func (l *pipeListener) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, server := net.Pipe()
+	select {
+	case l.conns <- server:
+		return client, nil
+	case <-l.done:
+	case <-ctx.Done():
+	}
+	_ = client.Close()
+	_ = server.Close()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, net.ErrClosed
+}
+
+// serveHandler starts serving the Handler of the runner if it is not
+// already being served and returns a function that stops serving.
+func (r *Runner) serveHandler() func() {
+	r.mu.Lock()
+	if r.handlerTransport == nil {
+		r.startHandler()
+	}
+	r.mu.Unlock()
+
+	return func() { _ = r.Close() }
+}
+
+// startHandler starts serving the Handler of the runner over an in-memory
+// listener. The runner mutex must be held.
+func (r *Runner) startHandler() {
+	ln := newPipeListener()
+	r.handlerSrv = &http.Server{Handler: r.Handler}
+	go func(srv *http.Server) { _ = srv.Serve(ln) }(r.handlerSrv)
+	r.handlerTransport = &http.Transport{DialContext: ln.dial}
+}
+
+// Close stops serving the Handler of the runner. It is only needed after
+// running a use case or step directly with UseCase.Run or Step.Execute as
+// Run, RunReport, and RunTests stop serving the Handler when they finish.
+func (r *Runner) Close() error {
+	r.mu.Lock()
+	t, srv := r.handlerTransport, r.handlerSrv
+	r.handlerTransport = nil
+	r.handlerSrv = nil
+	r.mu.Unlock()
+	if t == nil {
+		return nil
+	}
+	t.CloseIdleConnections()
+
+	return srv.Close()
+}
+
+// transport returns the transport to use when the runner does not have a
+// Client. If the runner has a Handler that is not being served yet it is
+// started.
+func (r *Runner) transport() http.RoundTripper {
+	if r.Transport != nil {
+		return r.Transport
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handlerTransport == nil && r.Handler != nil {
+		r.startHandler()
+	}
+	if r.handlerTransport != nil {
+		return r.handlerTransport
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestHandlerDirect(t *testing.T) {
+	wsh, results := wsHandler(TransportWS, func(p *wsPeer) error {
+		if err := p.expectJSON(`{"type":"connection_init"}`); err != nil {
+			return err
+		}
+		if err := p.writeJSON(map[string]interface{}{"type": "connection_ack"}); err != nil {
+			return err
+		}
+		if err := p.expectJSON(`{"id":"1","type":"subscribe","payload":{"query":"subscription {n}"}}`); err != nil {
+			return err
+		}
+		ev := map[string]interface{}{"id": "1", "type": "next", "payload": map[string]interface{}{"data": map[string]interface{}{"n": 1}}}
+		if err := p.writeJSON(ev); err != nil {
+			return err
+		}
+		if err := p.writeJSON(map[string]interface{}{"id": "1", "type": "complete"}); err != nil {
+			return err
+		}
+		return p.expect(wsClose, []byte{0x03, 0xE8})
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/ws", wsh)
+	mux.HandleFunc("/graphql", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"a":1}}`))
+	})
+	dir := writeFiles(t, map[string]string{
+		"direct.sen": `{steps: [
+  {label: query content: "{a}" expect: {data: {a: 1}}}
+  {label: sub path: "/ws" content: "subscription {n}" subscribe: {protocol: graphql-transport-ws} expect: [{data: {n: 1}}]}
+]}`,
+	})
+	r := Runner{
+		Base:    "/graphql",
+		Handler: mux,
+		Writer:  ioutil.Discard,
+	}
+	defer func() { _ = r.Close() }()
+
+	uc := loadUseCases(t, dir, "direct.sen")[0]
+	if err := uc.Run(&r); err != nil {
+		t.Fatal(err)
+	}
+	waitPeer(t, results)
+	if err := uc.Steps[0].Execute(uc); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The Handler is served again on the next request.
+	if err := uc.Steps[0].Execute(uc); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// headerPrefix is the prefix of a Remember path that identifies a response
+// header.
+const headerPrefix = "header:"
+
+// checkHeaders checks the response headers against the ExpectHeaders of the
+// step. A header matches if any of the values match or, if the expected
+// value is an array, if each value matches the corresponding element. A nil
+// expected value indicates the header must not be present.
+func (s *Step) checkHeaders(uc *UseCase, header http.Header) error {
+	names := make([]string, 0, len(s.ExpectHeaders))
+	for name := range s.ExpectHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expect := s.ExpectHeaders[name]
+		values := header.Values(name)
+		if expect == nil {
+			if 0 < len(values) {
+				return s.headerError(name, strings.Join(values, ", "), "absent")
+			}
+			continue
+		}
+		if len(values) == 0 {
+			return s.headerError(name, "absent", expect)
+		}
+		if !matchHeader(values, expect) {
+			uc.log(aResponse, "%s: %s", name, strings.Join(values, ", "))
+			return s.headerError(name, strings.Join(values, ", "), expect)
+		}
+	}
+	return nil
+}
+
+// matchHeader returns true if any of the header values match the expected
+// value. An expected array must have an element that matches each value in
+// order.
+func matchHeader(values []string, expect interface{}) bool {
+	if list, ok := expect.([]interface{}); ok {
+		if len(list) != len(values) {
+			return false
+		}
+		for i, v := range values {
+			if !matchString(v, list[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if matchString(v, expect) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Step) headerError(name string, actual, expect interface{}) error {
+	if pos := s.headersSrc.get(name); pos != nil {
+		return fmt.Errorf("%s header %s does not match expected (%s). %v != %v", s.Label, name, pos.pos, actual, expect)
+	}
+	return fmt.Errorf("%s header %s does not match expected. %v != %v", s.Label, name, actual, expect)
+}
+
+// rememberHeaders remembers the response headers identified by Remember
+// paths that start with "header:".
+func (s *Step) rememberHeaders(uc *UseCase, header http.Header) {
+	for k, path := range s.Remember {
+		if strings.HasPrefix(path, headerPrefix) {
+			uc.memory[k] = header.Get(strings.TrimSpace(path[len(headerPrefix):]))
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExpectHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Count", "12")
+		w.Header().Set("X-Flag", "true")
+		w.Header().Set("X-Tag", "abc")
+		w.Header().Add("X-Multi", "one")
+		w.Header().Add("X-Multi", "two")
+		_, _ = w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer ts.Close()
+
+	for _, c := range []struct {
+		expect string
+		fail   bool
+	}{
+		{expect: `{X-Count: 12}`},
+		{expect: `{X-Count: 13}`, fail: true},
+		{expect: `{X-Flag: true}`},
+		{expect: `{X-Flag: false}`, fail: true},
+		{expect: `{x-tag: abc}`},
+		{expect: `{X-Tag: "/^a.c$/"}`},
+		{expect: `{X-Tag: "/^ab$/"}`, fail: true},
+		{expect: `{X-Missing: null}`},
+		{expect: `{X-Tag: null}`, fail: true},
+		{expect: `{X-Missing: abc}`, fail: true},
+		{expect: `{X-Multi: two}`},
+		{expect: `{X-Multi: [one two]}`},
+		{expect: `{X-Multi: [one "/^t/"]}`},
+		{expect: `{X-Multi: [two one]}`, fail: true},
+		{expect: `{X-Multi: [one]}`, fail: true},
+		{expect: `{X-Tag: [abc]}`},
+	} {
+		dir := writeFiles(t, map[string]string{
+			"headers.sen": fmt.Sprintf(`{steps: [{label: headers content: "{a}" expectHeaders: %s}]}`, c.expect),
+		})
+		r := Runner{
+			Server:   ts.URL,
+			Writer:   ioutil.Discard,
+			UseCases: loadUseCases(t, dir, "headers.sen"),
+		}
+		err := r.Run()
+		switch {
+		case c.fail && err == nil:
+			t.Errorf("%s: expected a failure", c.expect)
+		case c.fail && !strings.Contains(err.Error(), "does not match expected"):
+			t.Errorf("%s: expected a header mismatch, got %s", c.expect, err)
+		case !c.fail && err != nil:
+			t.Errorf("%s: unexpected failure. %s", c.expect, err)
+		}
+	}
+}
+
+func TestMatchString(t *testing.T) {
+	for _, c := range []struct {
+		value  string
+		expect interface{}
+		match  bool
+	}{
+		{value: "abc", expect: "abc", match: true},
+		{value: "abc", expect: "/^abc$/", match: true},
+		{value: "abc", expect: "/^ab$/", match: false},
+		{value: "abc", expect: "/b/", match: true},
+		{value: "12", expect: int64(12), match: true},
+		{value: "1.5", expect: 1.5, match: true},
+		{value: "true", expect: true, match: true},
+		{value: "12", expect: "12", match: true},
+		{value: "", expect: nil, match: false},
+		{value: "a", expect: []interface{}{"a"}, match: false},
+	} {
+		if match := matchString(c.value, c.expect); match != c.match {
+			t.Errorf("%q %v: expected %t, got %t", c.value, c.expect, c.match, match)
+		}
+	}
+}
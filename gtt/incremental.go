@@ -0,0 +1,260 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"strings"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+// incrementalAccept is the Accept header value used to request incremental
+// delivery of @defer and @stream results.
+const incrementalAccept = "multipart/mixed; deferSpec=20220824, application/json"
+
+// incremental merges the payloads of an incremental delivery response into a
+// single result while recording each patch in the order received. Both the
+// current format where patches are in an "incremental" array and the earlier
+// format where each payload is a patch with a "path" are supported. Patches
+// that refer to a pending "id" instead of a "path" are also supported.
+type incremental struct {
+	result  map[string]interface{}
+	patches []interface{}
+	pending map[string][]interface{}
+}
+
+// isMultipart returns the boundary if the content type is multipart/mixed.
+func isMultipart(contentType string) (boundary string, ok bool) {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mt != "multipart/mixed" {
+		return "", false
+	}
+	return params["boundary"], true
+}
+
+// readIncremental reads a multipart/mixed incremental delivery response and
+// returns the merged result along with the list of patches.
+func readIncremental(boundary string, body []byte) (map[string]interface{}, []interface{}, error) {
+	if len(boundary) == 0 {
+		boundary = "-"
+	}
+	inc := incremental{result: map[string]interface{}{}, pending: map[string][]interface{}{}}
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	first := true
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+		var v interface{}
+		if v, err = oj.Parse(data); err != nil {
+			return nil, nil, err
+		}
+		payload, _ := v.(map[string]interface{})
+		if payload == nil {
+			return nil, nil, fmt.Errorf("expected a JSON object part, not %s", strings.TrimSpace(string(data)))
+		}
+		if first {
+			first = false
+			if _, has := payload["path"]; !has {
+				inc.initial(payload)
+				continue
+			}
+		}
+		if err = inc.subsequent(payload); err != nil {
+			return nil, nil, err
+		}
+	}
+	return inc.result, inc.patches, nil
+}
+
+func (inc *incremental) initial(payload map[string]interface{}) {
+	for k, v := range payload {
+		switch k {
+		case "hasNext", "pending":
+		default:
+			inc.result[k] = v
+		}
+	}
+	inc.addPending(payload["pending"])
+}
+
+func (inc *incremental) subsequent(payload map[string]interface{}) (err error) {
+	inc.addPending(payload["pending"])
+	if list, ok := payload["incremental"].([]interface{}); ok {
+		for _, v := range list {
+			if patch, _ := v.(map[string]interface{}); patch != nil {
+				if err = inc.apply(patch); err != nil {
+					return
+				}
+			}
+		}
+	} else if _, has := payload["path"]; has {
+		// Earlier format where the payload is the patch.
+		if err = inc.apply(payload); err != nil {
+			return
+		}
+	}
+	if list, ok := payload["completed"].([]interface{}); ok {
+		for _, v := range list {
+			if c, _ := v.(map[string]interface{}); c != nil {
+				inc.addErrors(c["errors"])
+			}
+		}
+	}
+	if ext, ok := payload["extensions"]; ok {
+		inc.result["extensions"] = ext
+	}
+	return
+}
+
+func (inc *incremental) addPending(v interface{}) {
+	list, _ := v.([]interface{})
+	for _, p := range list {
+		if pm, _ := p.(map[string]interface{}); pm != nil {
+			id, _ := pm["id"].(string)
+			path, _ := pm["path"].([]interface{})
+			inc.pending[id] = path
+		}
+	}
+}
+
+func (inc *incremental) addErrors(v interface{}) {
+	if list, ok := v.([]interface{}); ok && 0 < len(list) {
+		errs, _ := inc.result["errors"].([]interface{})
+		inc.result["errors"] = append(errs, list...)
+	}
+}
+
+// apply a single patch to the result.
+func (inc *incremental) apply(patch map[string]interface{}) error {
+	inc.patches = append(inc.patches, patch)
+	inc.addErrors(patch["errors"])
+	path, _ := patch["path"].([]interface{})
+	byID := false
+	if id, ok := patch["id"].(string); ok && path == nil {
+		var has bool
+		if path, has = inc.pending[id]; !has {
+			return fmt.Errorf("incremental patch refers to an unknown id %s", id)
+		}
+		sub, _ := patch["subPath"].([]interface{})
+		path = append(append([]interface{}{}, path...), sub...)
+		byID = true
+	}
+	if patch["data"] != nil && inc.result["data"] == nil {
+		inc.result["data"] = map[string]interface{}{}
+	}
+	if items, ok := patch["items"].([]interface{}); ok {
+		return inc.addItems(path, items, byID)
+	}
+	if data, ok := patch["data"].(map[string]interface{}); ok {
+		target, _ := inc.at(path).(map[string]interface{})
+		if target == nil {
+			return fmt.Errorf("incremental patch path %s does not identify an object", oj.JSON(path))
+		}
+		mergeData(target, data)
+	}
+	return nil
+}
+
+// addItems adds streamed items to the list identified by the path. With the
+// earlier format the last element of the path is the index of the first item
+// while with the id format the path identifies the list and items are
+// appended.
+func (inc *incremental) addItems(path []interface{}, items []interface{}, byID bool) error {
+	start := -1
+	if !byID && 0 < len(path) {
+		switch n := path[len(path)-1].(type) {
+		case int64:
+			start = int(n)
+			path = path[:len(path)-1]
+		case float64:
+			start = int(n)
+			path = path[:len(path)-1]
+		}
+	}
+	var parent interface{}
+	var key interface{}
+	if 0 < len(path) {
+		parent = inc.at(path[:len(path)-1])
+		key = path[len(path)-1]
+	}
+	list, ok := inc.at(path).([]interface{})
+	if !ok || parent == nil {
+		return fmt.Errorf("incremental patch path %s does not identify a list", oj.JSON(path))
+	}
+	if start < 0 || len(list) < start {
+		start = len(list)
+	}
+	list = append(list[:start], items...)
+	switch tp := parent.(type) {
+	case map[string]interface{}:
+		k, _ := key.(string)
+		tp[k] = list
+	case []interface{}:
+		if i, ok := pathIndex(key); ok {
+			tp[i] = list
+		}
+	}
+	return nil
+}
+
+// at returns the value in the result data at the path.
+func (inc *incremental) at(path []interface{}) interface{} {
+	v := inc.result["data"]
+	for _, key := range path {
+		switch tv := v.(type) {
+		case map[string]interface{}:
+			k, _ := key.(string)
+			v = tv[k]
+		case []interface{}:
+			i, ok := pathIndex(key)
+			if !ok || i < 0 || len(tv) <= i {
+				return nil
+			}
+			v = tv[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func pathIndex(key interface{}) (int, bool) {
+	switch n := key.(type) {
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// mergeData deep merges the data into the target map.
+func mergeData(target, data map[string]interface{}) {
+	for k, v := range data {
+		if vm, ok := v.(map[string]interface{}); ok {
+			if tm, ok := target[k].(map[string]interface{}); ok {
+				mergeData(tm, vm)
+				continue
+			}
+		}
+		target[k] = v
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+func multipartBody(t *testing.T, parts ...string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	if err := w.SetBoundary("-"); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range parts {
+		pw, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"application/json; charset=utf-8"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = pw.Write([]byte(p))
+	}
+	_ = w.Close()
+	return b.Bytes()
+}
+
+func TestReadIncremental(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		parts   []string
+		result  string
+		patches int
+		err     string
+	}{
+		{
+			name: "path defer",
+			parts: []string{
+				`{"data":{"hero":{"id":"1"}},"hasNext":true}`,
+				`{"path":["hero"],"data":{"name":"R2"},"hasNext":false}`,
+			},
+			result:  `{"data":{"hero":{"id":"1","name":"R2"}}}`,
+			patches: 1,
+		},
+		{
+			name: "path stream",
+			parts: []string{
+				`{"data":{"list":[1]},"hasNext":true}`,
+				`{"path":["list",1],"items":[2,3],"hasNext":true}`,
+				`{"path":["list",3],"items":[4],"hasNext":false}`,
+			},
+			result:  `{"data":{"list":[1,2,3,4]}}`,
+			patches: 2,
+		},
+		{
+			name: "path in incremental",
+			parts: []string{
+				`{"data":{"hero":{"id":"1"}},"hasNext":true}`,
+				`{"incremental":[{"path":["hero"],"data":{"name":"R2"}}],"hasNext":false}`,
+			},
+			result:  `{"data":{"hero":{"id":"1","name":"R2"}}}`,
+			patches: 1,
+		},
+		{
+			name: "id defer",
+			parts: []string{
+				`{"data":{"hero":{"id":"1"}},"pending":[{"id":"0","path":["hero"]}],"hasNext":true}`,
+				`{"incremental":[{"id":"0","data":{"name":"R2"}}],"completed":[{"id":"0"}],"hasNext":false}`,
+			},
+			result:  `{"data":{"hero":{"id":"1","name":"R2"}}}`,
+			patches: 1,
+		},
+		{
+			name: "id subPath",
+			parts: []string{
+				`{"data":{"hero":{"id":"1","friend":{"id":"2"}}},"pending":[{"id":"0","path":["hero"]}],"hasNext":true}`,
+				`{"incremental":[{"id":"0","subPath":["friend"],"data":{"name":"C3"}}],"completed":[{"id":"0"}],"hasNext":false}`,
+			},
+			result:  `{"data":{"hero":{"id":"1","friend":{"id":"2","name":"C3"}}}}`,
+			patches: 1,
+		},
+		{
+			name: "id stream",
+			parts: []string{
+				`{"data":{"list":[1]},"pending":[{"id":"1","path":["list"]}],"hasNext":true}`,
+				`{"incremental":[{"id":"1","items":[2,3]}],"hasNext":true}`,
+				`{"incremental":[{"id":"1","items":[4]}],"completed":[{"id":"1"}],"hasNext":false}`,
+			},
+			result:  `{"data":{"list":[1,2,3,4]}}`,
+			patches: 2,
+		},
+		{
+			name: "id pending later",
+			parts: []string{
+				`{"data":{"hero":{"id":"1"}},"pending":[{"id":"0","path":["hero"]}],"hasNext":true}`,
+				`{"pending":[{"id":"1","path":["hero","friend"]}],"incremental":[{"id":"0","data":{"friend":{"id":"2"}}}],"completed":[{"id":"0"}],"hasNext":true}`,
+				`{"incremental":[{"id":"1","data":{"name":"C3"}}],"completed":[{"id":"1"}],"hasNext":false}`,
+			},
+			result:  `{"data":{"hero":{"id":"1","friend":{"id":"2","name":"C3"}}}}`,
+			patches: 2,
+		},
+		{
+			name: "errors and extensions",
+			parts: []string{
+				`{"data":{"hero":{"id":"1"}},"pending":[{"id":"0","path":["hero"]}],"hasNext":true}`,
+				`{"completed":[{"id":"0","errors":[{"message":"boom"}]}],"extensions":{"x":1},"hasNext":false}`,
+			},
+			result: `{"data":{"hero":{"id":"1"}},"errors":[{"message":"boom"}],"extensions":{"x":1}}`,
+		},
+		{
+			name:  "unknown id",
+			parts: []string{`{"data":{}}`, `{"incremental":[{"id":"7","data":{"a":1}}]}`},
+			err:   "unknown id 7",
+		},
+		{
+			name:  "not an object",
+			parts: []string{`{"data":{"a":1}}`, `{"path":["a"],"data":{"b":1}}`},
+			err:   "does not identify an object",
+		},
+		{
+			name:  "not a list",
+			parts: []string{`{"data":{"a":1}}`, `{"path":["a",0],"items":[1]}`},
+			err:   "does not identify a list",
+		},
+		{
+			name:  "not a JSON object",
+			parts: []string{`[1]`},
+			err:   "expected a JSON object part",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			result, patches, err := readIncremental("-", multipartBody(t, c.parts...))
+			if 0 < len(c.err) {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("expected an error with %q, got %v", c.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			opt := oj.Options{Sort: true}
+			if s, x := oj.JSON(result, &opt), oj.JSON(oj.MustParseString(c.result), &opt); s != x {
+				t.Errorf("expected %s, got %s", x, s)
+			}
+			if len(patches) != c.patches {
+				t.Errorf("expected %d patches, got %d", c.patches, len(patches))
+			}
+		})
+	}
+}
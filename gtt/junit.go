@@ -0,0 +1,149 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut *junitOut     `xml:"system-out,omitempty"`
+}
+
+type junitOut struct {
+	Text string `xml:",cdata"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML. Each use case is a testsuite
+// named by the use case Filepath and each step is a testcase named by the
+// step Label. The setup and teardown, if present, are the first and last
+// testsuites. Request and response bodies are included in the system-out
+// element of each testcase. The steps of use cases that were not run are
+// skipped testcases.
+func (rep *Report) WriteJUnit(w io.Writer) error {
+	suites := junitSuites{Time: junitTime(rep.Duration)}
+	for _, ucr := range rep.results() {
+		suite := junitSuite{
+			Name: ucr.Filepath,
+			Time: junitTime(ucr.Duration),
+		}
+		if !ucr.start.IsZero() {
+			suite.Timestamp = ucr.start.Format("2006-01-02T15:04:05")
+		}
+		for i, sr := range ucr.Steps {
+			name := sr.Label
+			if len(name) == 0 {
+				name = fmt.Sprintf("step %d", i+1)
+			}
+			tc := junitCase{
+				Name:      name,
+				ClassName: ucr.Filepath,
+				Time:      junitTime(sr.Duration),
+			}
+			if out := sr.exchange(); 0 < len(out) {
+				tc.SystemOut = &junitOut{Text: out}
+			}
+			switch sr.Status {
+			case Fail:
+				tc.Failure = &junitFailure{Message: sr.Error, Type: "failure", Text: sr.Error}
+				if 0 < len(sr.Path) {
+					tc.Failure.Type = "mismatch"
+				}
+				if 0 < len(sr.Diff) {
+					tc.Failure.Text = sr.Diff
+				}
+				suite.Failures++
+			case Skip:
+				tc.Skipped = &struct{}{}
+				suite.Skipped++
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(&suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// exchange describes the request and response of the step. If the hash only
+// request of a persisted query step was followed by a request with the full
+// query both are described.
+func (sr *StepResult) exchange() string {
+	if len(sr.URL) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	if sr.APQ != nil && (sr.APQ.URL != sr.URL || sr.APQ.Request != sr.Request) {
+		writeExchange(&b, sr.APQ.Method, sr.APQ.URL, sr.APQ.Request, sr.APQ.StatusCode, sr.APQ.Response)
+		b.WriteByte('\n')
+	}
+	writeExchange(&b, sr.Method, sr.URL, sr.Request, sr.StatusCode, sr.Response)
+
+	return b.String()
+}
+
+func writeExchange(b *strings.Builder, method, u, request string, status int, response string) {
+	fmt.Fprintf(b, "%s %s\n", method, u)
+	if 0 < len(request) {
+		b.WriteString(request)
+		b.WriteByte('\n')
+	}
+	if 0 < status {
+		fmt.Fprintf(b, "\n[%d]\n", status)
+	}
+	if 0 < len(response) {
+		b.WriteString(response)
+		b.WriteByte('\n')
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const junitGolden = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="5" failures="1" skipped="2" time="3.500">
+  <testsuite name="suite.sen (setup)" tests="1" failures="0" skipped="0" time="0.250" timestamp="2022-03-04T05:06:07">
+    <testcase name="login" classname="suite.sen (setup)" time="0.250"></testcase>
+  </testsuite>
+  <testsuite name="a.sen" tests="2" failures="1" skipped="0" time="1.250" timestamp="2022-03-04T05:06:08">
+    <testcase name="first" classname="a.sen" time="0.500">
+      <system-out><![CDATA[POST http://localhost/graphql
+{a}
+
+[200]
+{"data":{"a":1}}
+]]></system-out>
+    </testcase>
+    <testcase name="step 2" classname="a.sen" time="0.750">
+      <failure message="step 2 result does not match" type="mismatch">  {&#xA;-   &#34;a&#34;: 2&#xA;+   &#34;a&#34;: 1&#xA;  }&#xA;</failure>
+    </testcase>
+  </testsuite>
+  <testsuite name="b.sen" tests="2" failures="0" skipped="2" time="0.000">
+    <testcase name="one" classname="b.sen" time="0.000">
+      <skipped></skipped>
+    </testcase>
+    <testcase name="two" classname="b.sen" time="0.000">
+      <skipped></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+
+func TestWriteJUnit(t *testing.T) {
+	start := time.Date(2022, 3, 4, 5, 6, 7, 0, time.Local)
+	rep := Report{
+		Start:    start,
+		Duration: 3500 * time.Millisecond,
+		Setup: &UseCaseResult{
+			Filepath: "suite.sen (setup)",
+			Status:   Pass,
+			Duration: 250 * time.Millisecond,
+			Steps:    []*StepResult{{Label: "login", Status: Pass, Duration: 250 * time.Millisecond}},
+			start:    start,
+		},
+		UseCases: []*UseCaseResult{
+			{
+				Filepath: "a.sen",
+				Status:   Fail,
+				Duration: 1250 * time.Millisecond,
+				Steps: []*StepResult{
+					{
+						Label:      "first",
+						Status:     Pass,
+						Duration:   500 * time.Millisecond,
+						Method:     "POST",
+						URL:        "http://localhost/graphql",
+						StatusCode: 200,
+						Request:    "{a}",
+						Response:   `{"data":{"a":1}}`,
+					},
+					{
+						Status:   Fail,
+						Duration: 750 * time.Millisecond,
+						Path:     "a",
+						Error:    "step 2 result does not match",
+						Diff:     "  {\n-   \"a\": 2\n+   \"a\": 1\n  }\n",
+					},
+				},
+				start: start.Add(time.Second),
+			},
+			{
+				Filepath: "b.sen",
+				Status:   Skip,
+				Steps:    []*StepResult{{Label: "one", Status: Skip}, {Label: "two", Status: Skip}},
+			},
+		},
+	}
+	var b bytes.Buffer
+	if err := rep.WriteJUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != junitGolden {
+		t.Errorf("expected\n%s\ngot\n%s", junitGolden, b.String())
+	}
+}
+
+func TestSkippedSteps(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"fail.sen": `{steps: [{label: failing content: "{a}" expect: {data: {a: 2}}}]}`,
+		"skip.sen": `{steps: [{label: one content: "{a}"} {label: two content: "{a}"}]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "fail.sen", "skip.sen"),
+	}
+	rep, _ := r.RunReport()
+	ucr := rep.UseCases[1]
+	if ucr.Status != Skip || len(ucr.Steps) != 2 {
+		t.Fatalf("expected a skipped use case with 2 steps, got %s with %d steps", ucr.Status, len(ucr.Steps))
+	}
+	for _, sr := range ucr.Steps {
+		if sr.Status != Skip {
+			t.Errorf("expected step %s to be skipped, got %s", sr.Label, sr.Status)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+const apolloManifestFormat = "apollo-persisted-query-manifest"
+
+// ManifestOperation is a single operation in a persisted operation manifest.
+type ManifestOperation struct {
+
+	// ID is the sha256 hash of the normalized document.
+	ID string
+
+	// Name of the operation if named.
+	Name string
+
+	// Type is the operation type, query, mutation, or subscription.
+	Type string
+
+	// Body is the normalized document.
+	Body string
+}
+
+// Manifest is a persisted operation manifest that maps document IDs to
+// GraphQL documents. Manifests can be written in the Apollo persisted query
+// manifest format or in the Relay format which is a simple map of ID to
+// document. When a Runner has a Manifest only the document ID is sent in
+// place of the full query.
+type Manifest struct {
+	ops map[string]*ManifestOperation
+}
+
+// NewManifest creates a manifest with the operations in the steps of the
+// use cases.
+func NewManifest(useCases []*UseCase) *Manifest {
+	m := &Manifest{}
+	for _, uc := range useCases {
+		for _, s := range uc.Steps {
+			if len(s.Batch) == 0 {
+				m.Add(s.Content, s.Op)
+			}
+			for _, o := range s.Batch {
+				m.Add(o.Content, o.Op)
+			}
+		}
+	}
+	return m
+}
+
+// ReadManifest reads a manifest file in either the Apollo or the Relay
+// format.
+func ReadManifest(filepath string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if v, err = oj.Parse(data); err != nil {
+		return nil, err
+	}
+	top, _ := v.(map[string]interface{})
+	if top == nil {
+		return nil, fmt.Errorf("expected a manifest object, not a %T", v)
+	}
+	m := &Manifest{ops: map[string]*ManifestOperation{}}
+	if format, _ := top["format"].(string); format == apolloManifestFormat {
+		list, _ := top["operations"].([]interface{})
+		for _, ov := range list {
+			om, _ := ov.(map[string]interface{})
+			op := ManifestOperation{}
+			op.ID, _ = om["id"].(string)
+			op.Name, _ = om["name"].(string)
+			op.Type, _ = om["type"].(string)
+			op.Body, _ = om["body"].(string)
+			if len(op.ID) == 0 || len(op.Body) == 0 {
+				return nil, fmt.Errorf("manifest operation %s is missing an id or body", oj.JSON(ov))
+			}
+			m.ops[op.ID] = &op
+		}
+		return m, nil
+	}
+	for id, bv := range top {
+		body, ok := bv.(string)
+		if !ok {
+			return nil, fmt.Errorf("manifest document for %s must be a string, not a %T", id, bv)
+		}
+		op := newManifestOperation(body, "")
+		op.ID = id
+		m.ops[id] = op
+	}
+	return m, nil
+}
+
+// Add an operation to the manifest and return the document ID. Empty
+// content is ignored.
+func (m *Manifest) Add(content, name string) string {
+	if len(strings.TrimSpace(content)) == 0 {
+		return ""
+	}
+	if m.ops == nil {
+		m.ops = map[string]*ManifestOperation{}
+	}
+	op := newManifestOperation(content, name)
+	if prev := m.ops[op.ID]; prev != nil {
+		return prev.ID
+	}
+	m.ops[op.ID] = op
+
+	return op.ID
+}
+
+// ID returns the document ID for the content if it is in the manifest.
+func (m *Manifest) ID(content string) (string, bool) {
+	body := NormalizeQuery(content)
+	id := queryHash(body)
+	if op := m.ops[id]; op != nil {
+		return id, true
+	}
+	// The manifest may have been generated by a different tool so compare
+	// the normalized documents.
+	for _, op := range m.ops {
+		if NormalizeQuery(op.Body) == body {
+			return op.ID, true
+		}
+	}
+	return "", false
+}
+
+// Operations returns the operations in the manifest sorted by ID.
+func (m *Manifest) Operations() []*ManifestOperation {
+	ops := make([]*ManifestOperation, 0, len(m.ops))
+	for _, op := range m.ops {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].ID < ops[j].ID })
+
+	return ops
+}
+
+// Apollo returns the manifest in the Apollo persisted query manifest format.
+func (m *Manifest) Apollo() interface{} {
+	list := []interface{}{}
+	for _, op := range m.Operations() {
+		om := map[string]interface{}{
+			"id":   op.ID,
+			"body": op.Body,
+			"type": op.Type,
+		}
+		if 0 < len(op.Name) {
+			om["name"] = op.Name
+		}
+		list = append(list, om)
+	}
+	return map[string]interface{}{
+		"format":     apolloManifestFormat,
+		"version":    1,
+		"operations": list,
+	}
+}
+
+// Relay returns the manifest in the Relay format, a map of ID to document.
+func (m *Manifest) Relay() interface{} {
+	native := map[string]interface{}{}
+	for id, op := range m.ops {
+		native[id] = op.Body
+	}
+	return native
+}
+
+func newManifestOperation(content, name string) *ManifestOperation {
+	body := NormalizeQuery(content)
+	op := ManifestOperation{ID: queryHash(body), Body: body, Name: name, Type: "query"}
+	if !strings.HasPrefix(body, "{") {
+		head := strings.FieldsFunc(body, func(r rune) bool {
+			return r == ' ' || r == '(' || r == '{' || r == '@'
+		})
+		if 0 < len(head) {
+			switch head[0] {
+			case "query", "mutation", "subscription":
+				op.Type = head[0]
+				if 1 < len(head) && len(name) == 0 {
+					op.Name = head[1]
+				}
+			}
+		}
+	}
+	return &op
+}
+
+func queryHash(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// NormalizeQuery returns the GraphQL document with comments removed and
+// white space and commas reduced to a single space only where needed to
+// separate names, numbers, and strings. Strings are not modified.
+func NormalizeQuery(q string) string {
+	var b strings.Builder
+	prevWord := false
+	prevString := false
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == '#':
+			for i < len(q) && q[i] != '\n' && q[i] != '\r' {
+				i++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '"':
+			// Adjacent strings are separated so that an empty string
+			// followed by another string is not read as a block string.
+			if prevString {
+				b.WriteByte(' ')
+			}
+			start := i
+			if strings.HasPrefix(q[i:], `"""`) {
+				i += 3
+				for i < len(q) && !strings.HasPrefix(q[i:], `"""`) {
+					if strings.HasPrefix(q[i:], `\"""`) {
+						i += 4
+						continue
+					}
+					i++
+				}
+				i += 3
+			} else {
+				i++
+				for i < len(q) && q[i] != '"' {
+					if q[i] == '\\' {
+						i++
+					}
+					i++
+				}
+				i++
+			}
+			if len(q) < i {
+				i = len(q)
+			}
+			b.WriteString(q[start:i])
+			prevWord = false
+			prevString = true
+		case isNameChar(c) || (c == '-' && i+1 < len(q) && '0' <= q[i+1] && q[i+1] <= '9'):
+			if prevWord {
+				b.WriteByte(' ')
+			}
+			start := i
+			number := c == '-' || ('0' <= c && c <= '9')
+			i++
+			for i < len(q) && (isNameChar(q[i]) || (number && strings.IndexByte(".eE+-", q[i]) != -1)) {
+				i++
+			}
+			b.WriteString(q[start:i])
+			prevWord = true
+			prevString = false
+		default:
+			b.WriteByte(c)
+			prevWord = false
+			prevString = false
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
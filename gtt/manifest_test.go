@@ -0,0 +1,42 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"testing"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		query  string
+		expect string
+	}{
+		{name: "names", query: "query  Q {\n  a\n  b\n}", expect: "query Q{a b}"},
+		{name: "commas", query: "{ a(x: 1, y: 2), b }", expect: "{a(x:1 y:2)b}"},
+		{name: "tabs and returns", query: "{\r\n\ta\r\n\tb\r\n}", expect: "{a b}"},
+		{name: "comments", query: "# top\n{\n  a # a, \"b\"\n  b #\r  c\n}", expect: "{a b c}"},
+		{name: "string", query: `{a(s: "one,  two # three")}`, expect: `{a(s:"one,  two # three")}`},
+		{name: "escaped quote", query: `{a(s: "x\"  y", t: "\\") b}`, expect: `{a(s:"x\"  y"t:"\\")b}`},
+		{name: "empty string", query: `{a(s: "", t: "") b}`, expect: `{a(s:""t:"")b}`},
+		{name: "adjacent strings", query: `{a(s: ["", "x", "y"])}`, expect: `{a(s:["" "x" "y"])}`},
+		{
+			name:   "block string",
+			query:  "{a(s: \"\"\"\n  one, \"two\"  # x\n  \\\"\"\" three\n\"\"\") b}",
+			expect: "{a(s:\"\"\"\n  one, \"two\"  # x\n  \\\"\"\" three\n\"\"\")b}",
+		},
+		{name: "integers", query: "{a(x: [1, 2 , -3, 0])}", expect: "{a(x:[1 2 -3 0])}"},
+		{name: "floats", query: "{a(x: 1.5e-3, y: -2.0E+10, z: 3e2)}", expect: "{a(x:1.5e-3 y:-2.0E+10 z:3e2)}"},
+		{name: "variables", query: "query Q($id: ID!, $n: Int = -10) { a(id: $id, n: $n) }", expect: "query Q($id:ID!$n:Int=-10){a(id:$id n:$n)}"},
+		{name: "fragments", query: "{ ... on User { id } ...F } fragment F on User { name }", expect: "{...on User{id}...F}fragment F on User{name}"},
+		{name: "directives", query: "{ a @include(if: $x) @skip(if: true) }", expect: "{a@include(if:$x)@skip(if:true)}"},
+		{name: "unterminated string", query: `{a(s: "abc`, expect: `{a(s:"abc`},
+		{name: "unterminated block string", query: `{a(s: """abc`, expect: `{a(s:"""abc`},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if q := NormalizeQuery(c.query); q != c.expect {
+				t.Errorf("expected %q, got %q", c.expect, q)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Position is a location in a use case source file.
+type Position struct {
+
+	// File is the path to the file.
+	File string
+
+	// Line is the line number starting at 1.
+	Line int
+
+	// Column is the column number starting at 1.
+	Column int
+}
+
+// String returns the position in the file:line:column format used by
+// editors and compilers.
+func (p Position) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+}
+
+// srcNode records the position of a value in a source file along with the
+// positions of the members of the value if it is a map or array. The
+// position of a map member is the position of the key.
+type srcNode struct {
+	pos   Position
+	keys  map[string]*srcNode
+	items []*srcNode
+}
+
+// get the node for a key if a map or an index if an array.
+func (n *srcNode) get(key interface{}) *srcNode {
+	if n == nil {
+		return nil
+	}
+	switch tk := key.(type) {
+	case string:
+		return n.keys[tk]
+	case int:
+		if 0 <= tk && tk < len(n.items) {
+			return n.items[tk]
+		}
+	}
+	return nil
+}
+
+// find the position of the deepest node along the location path.
+func (n *srcNode) find(loc []interface{}) *Position {
+	if n == nil {
+		return nil
+	}
+	for _, key := range loc {
+		child := n.get(key)
+		if child == nil {
+			break
+		}
+		n = child
+	}
+	return &n.pos
+}
+
+// locator scans SEN or JSON data and builds a tree of source positions. The
+// data is expected to have already been validated by a parser so error
+// handling is minimal. A nil node is returned if the data can not be
+// scanned.
+type locator struct {
+	file string
+	buf  []byte
+	off  int
+	line int
+	bol  int // offset of the beginning of the current line
+}
+
+func locate(file string, data []byte) (node *srcNode) {
+	defer func() {
+		if r := recover(); r != nil {
+			node = nil
+		}
+	}()
+	loc := locator{file: file, buf: data, line: 1}
+	// Skip BOM if present.
+	if 3 <= len(data) && data[0] == 0xEF && data[1] == 0xBB && data[2] == 0xBF {
+		loc.off = 3
+		loc.bol = 3
+	}
+	loc.skip()
+	return loc.value()
+}
+
+func (loc *locator) pos() Position {
+	return Position{
+		File:   loc.file,
+		Line:   loc.line,
+		Column: utf8.RuneCount(loc.buf[loc.bol:loc.off]) + 1,
+	}
+}
+
+// skip white space, commas, and comments.
+func (loc *locator) skip() {
+	for loc.off < len(loc.buf) {
+		switch b := loc.buf[loc.off]; b {
+		case '\n':
+			loc.off++
+			loc.line++
+			loc.bol = loc.off
+		case ' ', '\t', '\r', ',':
+			loc.off++
+		case '/':
+			if loc.off+1 < len(loc.buf) && loc.buf[loc.off+1] == '/' {
+				for loc.off < len(loc.buf) && loc.buf[loc.off] != '\n' {
+					loc.off++
+				}
+			} else {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (loc *locator) value() *srcNode {
+	node := &srcNode{pos: loc.pos()}
+	switch loc.buf[loc.off] {
+	case '{':
+		loc.off++
+		node.keys = map[string]*srcNode{}
+		for {
+			loc.skip()
+			if loc.buf[loc.off] == '}' {
+				loc.off++
+				break
+			}
+			kpos := loc.pos()
+			key := loc.key()
+			loc.skip()
+			if loc.buf[loc.off] == ':' {
+				loc.off++
+			}
+			loc.skip()
+			member := loc.value()
+			member.pos = kpos
+			node.keys[key] = member
+		}
+	case '[':
+		loc.off++
+		for {
+			loc.skip()
+			if loc.buf[loc.off] == ']' {
+				loc.off++
+				break
+			}
+			node.items = append(node.items, loc.value())
+		}
+	case '"', '\'':
+		loc.str()
+	default:
+		loc.token()
+	}
+	return node
+}
+
+func (loc *locator) key() string {
+	if b := loc.buf[loc.off]; b == '"' || b == '\'' {
+		return loc.str()
+	}
+	return loc.token()
+}
+
+// str reads a quoted string and returns the unescaped value.
+func (loc *locator) str() string {
+	quote := loc.buf[loc.off]
+	loc.off++
+	var s []byte
+	for loc.buf[loc.off] != quote {
+		b := loc.buf[loc.off]
+		if b == '\\' {
+			loc.off++
+			b = loc.buf[loc.off]
+			switch b {
+			case 'n':
+				b = '\n'
+			case 't':
+				b = '\t'
+			case 'r':
+				b = '\r'
+			case 'b':
+				b = '\b'
+			case 'f':
+				b = '\f'
+			case 'u':
+				var rb [utf8.UTFMax]byte
+				n := utf8.EncodeRune(rb[:], loc.unicode())
+				s = append(s, rb[:n]...)
+				loc.off++
+				continue
+			}
+		} else if b == '\n' {
+			loc.line++
+			loc.bol = loc.off + 1
+		}
+		s = append(s, b)
+		loc.off++
+	}
+	loc.off++
+	return string(s)
+}
+
+// unicode reads the hex digits of a \u escape leaving the offset on the last
+// digit. Surrogates are not combined, matching the parser.
+func (loc *locator) unicode() rune {
+	n, err := strconv.ParseUint(string(loc.buf[loc.off+1:loc.off+5]), 16, 32)
+	if err != nil {
+		panic(err)
+	}
+	loc.off += 4
+	return rune(n)
+}
+
+// token reads a token, number, or literal.
+func (loc *locator) token() string {
+	start := loc.off
+	for loc.off < len(loc.buf) {
+		switch loc.buf[loc.off] {
+		case ' ', '\t', '\r', '\n', ',', ':', '[', ']', '{', '}', '"', '\'', '/':
+			if start == loc.off {
+				panic(fmt.Errorf("unexpected character at %s", loc.pos().String()))
+			}
+			return string(loc.buf[start:loc.off])
+		}
+		loc.off++
+	}
+	return string(loc.buf[start:loc.off])
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ohler55/ojg/sen"
+)
+
+func TestLocate(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		data   string
+		path   []interface{}
+		expect string
+	}{
+		{name: "json", data: `{"a": 1, "b": [1, {"c": true}]}`, path: []interface{}{"b", 1, "c"}, expect: "f.json:1:20"},
+		{name: "root", data: "\n\n  {a: 1}", path: nil, expect: "f.json:3:3"},
+		{name: "unquoted", data: "{\n  one: 1\n  two: {three: x}\n}", path: []interface{}{"two", "three"}, expect: "f.json:3:9"},
+		{name: "single quoted", data: "{'a b': 1 'c': 2}", path: []interface{}{"c"}, expect: "f.json:1:11"},
+		{name: "comment", data: "{\n  // a: 0\n  a: 1 // b: 0\n  b: 2\n}", path: []interface{}{"b"}, expect: "f.json:4:3"},
+		{name: "comment after token", data: "{a: x// b: 0\n b: 2}", path: []interface{}{"b"}, expect: "f.json:2:2"},
+		{name: "escaped quote", data: `{"a\"b": 1, "c": 2}`, path: []interface{}{`a"b`}, expect: "f.json:1:2"},
+		{name: "escaped unicode", data: `{"\u00e9t\u00E9": 1, "c": 2}`, path: []interface{}{"été"}, expect: "f.json:1:2"},
+		{name: "after escaped unicode", data: `{"\u00e9": 1, "c": 2}`, path: []interface{}{"c"}, expect: "f.json:1:15"},
+		{name: "surrogate pair", data: `{"\ud83d\ude00": 1, "c": 2}`, path: []interface{}{"\ufffd\ufffd"}, expect: "f.json:1:2"},
+		{name: "escapes before key", data: `{"a": "x\\\n\t\"", "b": 2}`, path: []interface{}{"b"}, expect: "f.json:1:20"},
+		{name: "multibyte column", data: `{"é": "ü", "b": 2}`, path: []interface{}{"b"}, expect: "f.json:1:12"},
+		{name: "multi-line string", data: "{a: \"one\ntwo\nthree\" b: 2}", path: []interface{}{"b"}, expect: "f.json:3:8"},
+		{name: "multi-line array", data: "{\n steps: [\n  {a: 1}\n  {a: 2}\n ]\n}", path: []interface{}{"steps", 1, "a"}, expect: "f.json:4:4"},
+		{name: "bom", data: "\xEF\xBB\xBF{\"a\": 1,\n \"b\": 2}", path: []interface{}{"b"}, expect: "f.json:2:2"},
+		{name: "bom first line", data: "\xEF\xBB\xBF{\"a\": 1}", path: []interface{}{"a"}, expect: "f.json:1:2"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var p sen.Parser
+			v, err := p.Parse([]byte(c.data))
+			if err != nil {
+				t.Fatalf("parse failed. %s", err)
+			}
+			node := locate("f.json", []byte(c.data))
+			if node == nil {
+				t.Fatal("locate returned nil")
+			}
+			for _, key := range c.path {
+				// The parser and the locator must agree on the keys and
+				// indexes.
+				switch tv := v.(type) {
+				case map[string]interface{}:
+					var has bool
+					if v, has = tv[key.(string)]; !has {
+						t.Fatalf("parser did not find %v", key)
+					}
+				case []interface{}:
+					v = tv[key.(int)]
+				}
+				if node = node.get(key); node == nil {
+					t.Fatalf("locator did not find %v", key)
+				}
+			}
+			if pos := node.pos.String(); pos != c.expect {
+				t.Errorf("expected %s, got %s", c.expect, pos)
+			}
+		})
+	}
+}
+
+func TestLocateInvalid(t *testing.T) {
+	if node := locate("f.json", []byte(`{"a": `)); node != nil {
+		t.Errorf("expected nil for truncated data, got %v", node.pos)
+	}
+}
+
+func TestUseCasePositions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"inc/steps.sen": `[
+  // included step
+  {label: included content: "{a}"
+   expect: {data: {a: 1}}}
+]`,
+		"case.sen": "\xEF\xBB\xBF" + `{
+  comment: "positions"
+  steps: [
+    {label: first content: "{a}" expect: {data: {a: 1}}}
+    "inc/steps.sen"
+    {
+      label: last
+      expect: {"data": {"a": 2}}
+    }
+  ]
+}`,
+	})
+	uc, err := NewUseCase(filepath.Join(dir, "case.sen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uc.Steps) != 3 {
+		t.Fatalf("expected 3 steps, got %d", len(uc.Steps))
+	}
+	for i, c := range []struct {
+		step   string
+		expect string
+	}{
+		{step: "case.sen:4:5", expect: "case.sen:4:50"},
+		{step: "inc/steps.sen:3:3", expect: "inc/steps.sen:4:20"},
+		{step: "case.sen:6:5", expect: "case.sen:8:25"},
+	} {
+		s := uc.Steps[i]
+		if pos := rel(dir, s.Position); pos != c.step {
+			t.Errorf("step %d expected position %s, got %s", i, c.step, pos)
+		}
+		if pos := rel(dir, s.expectSrc.get("data").get("a").find(nil)); pos != c.expect {
+			t.Errorf("step %d expected expect position %s, got %s", i, c.expect, pos)
+		}
+	}
+}
+
+func rel(dir string, pos *Position) string {
+	if pos == nil {
+		return "<nil>"
+	}
+	p := *pos
+	p.File, _ = filepath.Rel(dir, p.File)
+	p.File = filepath.ToSlash(p.File)
+
+	return p.String()
+}
@@ -0,0 +1,424 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+// Status is the outcome of a use case or step.
+type Status string
+
+const (
+	// Pass indicates the use case or step succeeded.
+	Pass = Status("pass")
+	// Fail indicates the use case or step failed.
+	Fail = Status("fail")
+	// Skip indicates the use case or step was not run.
+	Skip = Status("skip")
+)
+
+// Report is the result of a Runner run. It includes the outcome of each use
+// case and each step in the use cases so that results can be consumed
+// programmatically.
+type Report struct {
+
+	// Start is the time the run started.
+	Start time.Time
+
+	// Duration of the run.
+	Duration time.Duration
+
+	// UseCases are the results of each use case in the order they appear in
+	// the Runner.
+	UseCases []*UseCaseResult
+
+	// Setup is the result of the Runner Setup if there was one.
+	Setup *UseCaseResult
+
+	// Teardown is the result of the Runner Teardown if there was one.
+	Teardown *UseCaseResult
+}
+
+// UseCaseResult is the result of running a use case.
+type UseCaseResult struct {
+
+	// Filepath of the use case.
+	Filepath string
+
+	// Status of the use case.
+	Status Status
+
+	// Duration of the use case run.
+	Duration time.Duration
+
+	// Error is the error message for the first step that failed if the use
+	// case failed.
+	Error string
+
+	// Steps are the results of each step.
+	Steps []*StepResult
+
+	// Memory is the remembered values at the end of the use case.
+	Memory map[string]interface{}
+
+	err   error
+	start time.Time
+}
+
+// StepResult is the result of executing a step.
+type StepResult struct {
+
+	// Label of the step.
+	Label string
+
+	// Status of the step.
+	Status Status
+
+	// Duration of the step.
+	Duration time.Duration
+
+	// Method of the HTTP request.
+	Method string
+
+	// URL of the HTTP request.
+	URL string
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Request is the content of the HTTP request.
+	Request string
+
+	// Response is the body of the HTTP response.
+	Response string
+
+	// Path is the path in the response where the first mismatch with the
+	// expected value was detected.
+	Path string
+
+	// Mismatches are all the differences between the expected value and the
+	// response.
+	Mismatches []*Mismatch
+
+	// Diff is a unified diff of the expected value and the actual response
+	// if the response did not match.
+	Diff string
+
+	// Error is the error message if the step failed.
+	Error string
+
+	// Memory is a snapshot of the remembered values after the step
+	// completed.
+	Memory map[string]interface{}
+
+	// APQ is the hash only request and response of a step that uses the
+	// Automatic Persisted Queries protocol. The Method, URL, Request, and
+	// Response of the step result are those of the final request which
+	// includes the full query if the hash only request was a miss.
+	APQ *APQResult
+
+	err error
+}
+
+// APQResult is the hash only request of an Automatic Persisted Queries step
+// and the response to it.
+type APQResult struct {
+
+	// Method of the HTTP request.
+	Method string
+
+	// URL of the HTTP request.
+	URL string
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Request is the content of the HTTP request.
+	Request string
+
+	// Response is the body of the HTTP response.
+	Response string
+
+	// Miss is true if the response was a PersistedQueryNotFound error.
+	Miss bool
+}
+
+// Native version of the APQ result.
+func (ar *APQResult) Native() interface{} {
+	native := map[string]interface{}{
+		"method": ar.Method,
+		"url":    ar.URL,
+		"miss":   ar.Miss,
+	}
+	if 0 < ar.StatusCode {
+		native["statusCode"] = ar.StatusCode
+	}
+	if 0 < len(ar.Request) {
+		native["request"] = ar.Request
+	}
+	if 0 < len(ar.Response) {
+		native["response"] = ar.Response
+	}
+	return native
+}
+
+// Mismatch describes a difference between an expected value and the actual
+// value in a response.
+type Mismatch struct {
+
+	// Path is the dot delimited path to the value.
+	Path string
+
+	// JSONPath is the JSONPath to the value.
+	JSONPath string
+
+	// Expect is the expected value. It is nil if the actual value was not
+	// expected.
+	Expect interface{}
+
+	// Actual is the actual value. It is nil if the expected value is not
+	// present.
+	Actual interface{}
+
+	// Position of the expected value in the use case file if known. If the
+	// actual value was not expected the position is that of the closest
+	// enclosing expected value.
+	Position *Position
+
+	loc []interface{}
+}
+
+// Native version of the mismatch.
+func (m *Mismatch) Native() interface{} {
+	native := map[string]interface{}{
+		"path":     m.Path,
+		"jsonPath": m.JSONPath,
+		"expect":   m.Expect,
+		"actual":   m.Actual,
+	}
+	if m.Position != nil {
+		native["position"] = m.Position.String()
+	}
+	return native
+}
+
+// Passed returns true if no use case, setup, or teardown in the report
+// failed.
+func (rep *Report) Passed() bool {
+	for _, ucr := range rep.results() {
+		if ucr.Status == Fail {
+			return false
+		}
+	}
+	return true
+}
+
+// Counts returns the number of use cases that passed, failed, and were
+// skipped.
+func (rep *Report) Counts() (pass, fail, skip int) {
+	for _, ucr := range rep.UseCases {
+		switch ucr.Status {
+		case Pass:
+			pass++
+		case Fail:
+			fail++
+		default:
+			skip++
+		}
+	}
+	return
+}
+
+// StepCounts returns the number of steps across all use cases that passed,
+// failed, and were skipped.
+func (rep *Report) StepCounts() (pass, fail, skip int) {
+	for _, ucr := range rep.UseCases {
+		for _, sr := range ucr.Steps {
+			switch sr.Status {
+			case Pass:
+				pass++
+			case Fail:
+				fail++
+			default:
+				skip++
+			}
+		}
+	}
+	return
+}
+
+// results returns the setup, use case, and teardown results in the order
+// they were run.
+func (rep *Report) results() []*UseCaseResult {
+	results := make([]*UseCaseResult, 0, len(rep.UseCases)+2)
+	if rep.Setup != nil {
+		results = append(results, rep.Setup)
+	}
+	results = append(results, rep.UseCases...)
+	if rep.Teardown != nil {
+		results = append(results, rep.Teardown)
+	}
+	return results
+}
+
+func (rep *Report) summary(color bool) string {
+	var b strings.Builder
+	width := 0
+	results := rep.results()
+	for _, ucr := range results {
+		if width < len(ucr.Filepath) {
+			width = len(ucr.Filepath)
+		}
+	}
+	b.WriteString("\nSummary\n")
+	for _, ucr := range results {
+		label := strings.ToUpper(string(ucr.Status))
+		if color {
+			switch ucr.Status {
+			case Pass:
+				label = green + label + normal
+			case Fail:
+				label = red + label + normal
+			default:
+				label = yellow + label + normal
+			}
+		}
+		if ucr.Status == Skip {
+			fmt.Fprintf(&b, "  %s  %s\n", label, ucr.Filepath)
+			continue
+		}
+		fmt.Fprintf(&b, "  %s  %-*s  %.3fs\n", label, width, ucr.Filepath, ucr.Duration.Seconds())
+		for _, sr := range ucr.Steps {
+			if sr.Status == Fail {
+				fmt.Fprintf(&b, "          %s: %s\n", sr.Label, sr.Error)
+			}
+		}
+	}
+	pass, fail, skip := rep.Counts()
+	fmt.Fprintf(&b, "Use cases: %d passed, %d failed, %d skipped\n", pass, fail, skip)
+	pass, fail, skip = rep.StepCounts()
+	fmt.Fprintf(&b, "Steps:     %d passed, %d failed, %d skipped\n", pass, fail, skip)
+
+	return b.String()
+}
+
+// String representation of the report.
+func (rep *Report) String() string {
+	return string(rep.JSON())
+}
+
+// JSON representation of the report.
+func (rep *Report) JSON(indents ...int) []byte {
+	indent := 0
+	if 0 < len(indents) {
+		indent = indents[0]
+	}
+	return []byte(oj.JSON(rep, indent))
+}
+
+// Native version of the report.
+func (rep *Report) Native() interface{} {
+	cases := make([]interface{}, 0, len(rep.UseCases))
+	for _, ucr := range rep.UseCases {
+		cases = append(cases, ucr.Native())
+	}
+	native := map[string]interface{}{
+		"start":    rep.Start.Format(time.RFC3339Nano),
+		"duration": rep.Duration.Seconds(),
+		"passed":   rep.Passed(),
+		"useCases": cases,
+	}
+	if rep.Setup != nil {
+		native["setup"] = rep.Setup.Native()
+	}
+	if rep.Teardown != nil {
+		native["teardown"] = rep.Teardown.Native()
+	}
+	return native
+}
+
+// Simplify returns a simplified version of the report.
+func (rep *Report) Simplify() interface{} {
+	return rep.Native()
+}
+
+// Native version of the use case result.
+func (ucr *UseCaseResult) Native() interface{} {
+	steps := make([]interface{}, 0, len(ucr.Steps))
+	for _, sr := range ucr.Steps {
+		steps = append(steps, sr.Native())
+	}
+	native := map[string]interface{}{
+		"filepath": ucr.Filepath,
+		"status":   string(ucr.Status),
+		"duration": ucr.Duration.Seconds(),
+		"steps":    steps,
+	}
+	if 0 < len(ucr.Error) {
+		native["error"] = ucr.Error
+	}
+	if ucr.Memory != nil {
+		native["memory"] = ucr.Memory
+	}
+
+	return native
+}
+
+// Native version of the step result.
+func (sr *StepResult) Native() interface{} {
+	native := map[string]interface{}{
+		"label":    sr.Label,
+		"status":   string(sr.Status),
+		"duration": sr.Duration.Seconds(),
+	}
+	if 0 < len(sr.URL) {
+		native["method"] = sr.Method
+		native["url"] = sr.URL
+	}
+	if 0 < sr.StatusCode {
+		native["statusCode"] = sr.StatusCode
+	}
+	if 0 < len(sr.Request) {
+		native["request"] = sr.Request
+	}
+	if 0 < len(sr.Response) {
+		native["response"] = sr.Response
+	}
+	if 0 < len(sr.Path) {
+		native["path"] = sr.Path
+	}
+	if 0 < len(sr.Mismatches) {
+		list := make([]interface{}, 0, len(sr.Mismatches))
+		for _, m := range sr.Mismatches {
+			list = append(list, m.Native())
+		}
+		native["mismatches"] = list
+	}
+	if 0 < len(sr.Diff) {
+		native["diff"] = sr.Diff
+	}
+	if 0 < len(sr.Error) {
+		native["error"] = sr.Error
+	}
+	if sr.Memory != nil {
+		native["memory"] = sr.Memory
+	}
+	if sr.APQ != nil {
+		native["apq"] = sr.APQ.Native()
+	}
+
+	return native
+}
+
+func copyMemory(memory map[string]interface{}) map[string]interface{} {
+	dup := make(map[string]interface{}, len(memory))
+	for k, v := range memory {
+		dup[k] = v
+	}
+	return dup
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ohler55/ojg/jp"
+	"github.com/ohler55/ojg/oj"
+)
+
+func TestRunReport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"a":1,"b":"x"}}`))
+	}))
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"a.sen": `{steps: [{label: one content: "{a}" remember: {a: "data.a"} expect: {data: {a: 1}}}]}`,
+		"b.sen": `{steps: [
+  {label: one content: "{b}" expect: {data: {b: y}}}
+  {label: two content: "{a}"}
+]}`,
+		"c.sen": `{steps: [{label: one content: "{a}"}]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Base:     "/graphql",
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "a.sen", "b.sen", "c.sen"),
+	}
+	rep, err := r.RunReport()
+	if err == nil {
+		t.Fatal("expected a failure")
+	}
+	if len(rep.UseCases) != 3 {
+		t.Fatalf("expected 3 use case results, got %d", len(rep.UseCases))
+	}
+	for i, status := range []Status{Pass, Fail, Skip} {
+		if ucr := rep.UseCases[i]; ucr.Status != status {
+			t.Errorf("expected %s to be %s, got %s", ucr.Filepath, status, ucr.Status)
+		}
+	}
+
+	a := rep.UseCases[0]
+	if a.Filepath != filepath.Join(dir, "a.sen") || a.Memory["a"] != int64(1) || len(a.Steps) != 1 {
+		t.Errorf("unexpected use case result %s", oj.JSON(a, 2))
+	}
+	sr := a.Steps[0]
+	switch {
+	case sr.Status != Pass || sr.Label != "one":
+		t.Errorf("expected step one to pass, got %s", sr.Status)
+	case sr.Method != "POST" || sr.URL != ts.URL+"/graphql" || sr.StatusCode != 200:
+		t.Errorf("unexpected request %s %s %d", sr.Method, sr.URL, sr.StatusCode)
+	case sr.Request != "{a}" || sr.Response != `{"data":{"a":1,"b":"x"}}`:
+		t.Errorf("unexpected exchange %s %s", sr.Request, sr.Response)
+	case sr.Memory["a"] != int64(1):
+		t.Errorf("expected a memory snapshot, got %v", sr.Memory)
+	case sr.Duration <= 0:
+		t.Errorf("expected a step duration, got %s", sr.Duration)
+	}
+
+	b := rep.UseCases[1]
+	if b.Error != err.Error() || len(b.Steps) != 2 {
+		t.Fatalf("unexpected use case result %s", oj.JSON(b, 2))
+	}
+	sr = b.Steps[0]
+	if sr.Status != Fail || sr.Path != "data.b" || len(sr.Mismatches) != 1 || !strings.Contains(sr.Error, "does not match") {
+		t.Errorf("unexpected failed step result %s", oj.JSON(sr, 2))
+	}
+	if sr = b.Steps[1]; sr.Status != Skip || len(sr.URL) != 0 {
+		t.Errorf("expected step two to be skipped, got %s", oj.JSON(sr, 2))
+	}
+
+	if pass, fail, skip := rep.Counts(); pass != 1 || fail != 1 || skip != 1 {
+		t.Errorf("expected use case counts 1 1 1, got %d %d %d", pass, fail, skip)
+	}
+	if pass, fail, skip := rep.StepCounts(); pass != 1 || fail != 1 || skip != 2 {
+		t.Errorf("expected step counts 1 1 2, got %d %d %d", pass, fail, skip)
+	}
+	if rep.Passed() {
+		t.Error("expected the report to not pass")
+	}
+
+	v, perr := oj.Parse(rep.JSON())
+	if perr != nil {
+		t.Fatal(perr)
+	}
+	for path, expect := range map[string]interface{}{
+		"$.passed":                          false,
+		"$.useCases[0].status":              "pass",
+		"$.useCases[0].memory.a":            int64(1),
+		"$.useCases[0].steps[0].url":        ts.URL + "/graphql",
+		"$.useCases[0].steps[0].statusCode": int64(200),
+		"$.useCases[1].steps[0].path":       "data.b",
+		"$.useCases[2].status":              "skip",
+	} {
+		if got := jp.MustParseString(path).First(v); got != expect {
+			t.Errorf("expected %v at %s, got %v", expect, path, got)
+		}
+	}
+}
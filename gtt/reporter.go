@@ -0,0 +1,200 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+// EventKind identifies the kind of an Event.
+type EventKind string
+
+const (
+	// RunStart is the kind of event emitted when a run starts.
+	RunStart = EventKind("runStart")
+	// RunEnd is the kind of event emitted when a run ends.
+	RunEnd = EventKind("runEnd")
+	// UseCaseStart is the kind of event emitted before a use case is run.
+	UseCaseStart = EventKind("useCaseStart")
+	// UseCaseEnd is the kind of event emitted after a use case is run or
+	// skipped.
+	UseCaseEnd = EventKind("useCaseEnd")
+	// StepStart is the kind of event emitted before a step is executed.
+	StepStart = EventKind("stepStart")
+	// StepEnd is the kind of event emitted after a step is executed or
+	// skipped.
+	StepEnd = EventKind("stepEnd")
+	// RequestSent is the kind of event emitted when a request is sent.
+	RequestSent = EventKind("request")
+	// ResponseReceived is the kind of event emitted when a response is
+	// received.
+	ResponseReceived = EventKind("response")
+	// MismatchFound is the kind of event emitted when a response does not
+	// match the expected value.
+	MismatchFound = EventKind("mismatch")
+)
+
+// Event describes something that happened during a run. Only the fields
+// relevant to the Kind are set.
+type Event struct {
+
+	// Kind of the event.
+	Kind EventKind
+
+	// Time the event occurred.
+	Time time.Time
+
+	// Filepath of the use case the event is for.
+	Filepath string
+
+	// Step is the label of the step the event is for.
+	Step string
+
+	// Count is the number of use cases in the run for a RunStart event.
+	Count int
+
+	// Method of an HTTP request.
+	Method string
+
+	// URL of an HTTP request.
+	URL string
+
+	// Content is the content of a request or the body of a response.
+	Content string
+
+	// StatusCode of a response.
+	StatusCode int
+
+	// Path of a mismatch.
+	Path string
+
+	// JSONPath of a mismatch.
+	JSONPath string
+
+	// Expect is the expected value of a mismatch.
+	Expect interface{}
+
+	// Actual is the actual value of a mismatch.
+	Actual interface{}
+
+	// Status of a run, use case, or step end event.
+	Status Status
+
+	// Duration of a run, use case, or step for an end event.
+	Duration time.Duration
+
+	// Error message for a failed use case or step.
+	Error string
+}
+
+// Native version of the event.
+func (ev *Event) Native() interface{} {
+	native := map[string]interface{}{
+		"kind": string(ev.Kind),
+		"time": ev.Time.Format(time.RFC3339Nano),
+	}
+	if 0 < len(ev.Filepath) {
+		native["filepath"] = ev.Filepath
+	}
+	if 0 < len(ev.Step) {
+		native["step"] = ev.Step
+	}
+	switch ev.Kind {
+	case RunStart:
+		native["count"] = ev.Count
+	case RequestSent:
+		native["method"] = ev.Method
+		native["url"] = ev.URL
+		native["content"] = ev.Content
+	case ResponseReceived:
+		native["statusCode"] = ev.StatusCode
+		native["content"] = ev.Content
+	case MismatchFound:
+		native["path"] = ev.Path
+		native["jsonPath"] = ev.JSONPath
+		native["expect"] = ev.Expect
+		native["actual"] = ev.Actual
+	case RunEnd, UseCaseEnd, StepEnd:
+		native["status"] = string(ev.Status)
+		native["duration"] = ev.Duration.Seconds()
+		if 0 < len(ev.Error) {
+			native["error"] = ev.Error
+		}
+	}
+	return native
+}
+
+// Reporter is notified of events during a run. Events are delivered one at
+// a time even when use cases are run concurrently.
+type Reporter interface {
+	// Report an event.
+	Report(ev *Event)
+}
+
+// JSONReporter writes each event as a JSON object on a single line.
+type JSONReporter struct {
+
+	// Writer to write events to.
+	Writer io.Writer
+}
+
+// Report an event.
+func (jr *JSONReporter) Report(ev *Event) {
+	_, _ = io.WriteString(jr.Writer, oj.JSON(ev.Native(), &oj.Options{Sort: true})+"\n")
+}
+
+// TAPReporter writes step results in the Test Anything Protocol version 13
+// format. Each step is a test point described by the use case file and step
+// label so concurrent use cases can be told apart. The plan is written at
+// the end of the run.
+type TAPReporter struct {
+
+	// Writer to write the TAP output to.
+	Writer io.Writer
+
+	count int
+}
+
+// Report an event.
+func (tr *TAPReporter) Report(ev *Event) {
+	var b strings.Builder
+	switch ev.Kind {
+	case RunStart:
+		tr.count = 0
+		b.WriteString("TAP version 13\n")
+	case UseCaseEnd:
+		if ev.Status == Skip {
+			tr.count++
+			fmt.Fprintf(&b, "ok %d - %s # SKIP not run\n", tr.count, tapEscape(ev.Filepath))
+		}
+	case StepEnd:
+		tr.count++
+		desc := tapEscape(fmt.Sprintf("%s: %s", ev.Filepath, ev.Step))
+		switch ev.Status {
+		case Pass:
+			fmt.Fprintf(&b, "ok %d - %s\n", tr.count, desc)
+		case Skip:
+			fmt.Fprintf(&b, "ok %d - %s # SKIP\n", tr.count, desc)
+		default:
+			fmt.Fprintf(&b, "not ok %d - %s\n", tr.count, desc)
+			b.WriteString("  ---\n")
+			fmt.Fprintf(&b, "  message: %s\n", oj.JSON(ev.Error))
+			fmt.Fprintf(&b, "  duration_ms: %.3f\n", float64(ev.Duration.Microseconds())/1000.0)
+			b.WriteString("  ...\n")
+		}
+	case RunEnd:
+		fmt.Fprintf(&b, "1..%d\n", tr.count)
+	}
+	_, _ = io.WriteString(tr.Writer, b.String())
+}
+
+func tapEscape(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "#", "\\#")
+	return strings.ReplaceAll(s, "\n", " ")
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTAPReporter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"a.sen": `{steps: [{label: one content: "{a}"} {label: two content: "{a}" expect: {data: {a: 2}}}]}`,
+		"b.sen": `{steps: [{label: one content: "{a}"}]}`,
+	})
+	var tap bytes.Buffer
+	r := Runner{
+		Server:      ts.URL,
+		Concurrency: 2,
+		Continue:    true,
+		Writer:      ioutil.Discard,
+		Reporters:   []Reporter{&TAPReporter{Writer: &tap}},
+		UseCases:    loadUseCases(t, dir, "a.sen", "b.sen"),
+	}
+	if err := r.Run(); err == nil {
+		t.Fatal("expected a failure")
+	}
+	var points []string
+	var plan string
+	for i, line := range strings.Split(strings.TrimSuffix(tap.String(), "\n"), "\n") {
+		switch {
+		case i == 0:
+			if line != "TAP version 13" {
+				t.Errorf("expected a TAP header, got %s", line)
+			}
+		case strings.HasPrefix(line, "ok ") || strings.HasPrefix(line, "not ok "):
+			// Strip the test point number as the order depends on the
+			// concurrent use cases.
+			parts := strings.SplitN(line, " - ", 2)
+			status := parts[0][:strings.LastIndexByte(parts[0], ' ')]
+			points = append(points, status+" "+parts[1])
+		case strings.HasPrefix(line, "1.."):
+			plan = line
+		case strings.HasPrefix(line, "  "):
+		default:
+			t.Errorf("unexpected TAP line %q", line)
+		}
+	}
+	sort.Strings(points)
+	expect := []string{
+		"not ok " + filepath.Join(dir, "a.sen") + ": two",
+		"ok " + filepath.Join(dir, "a.sen") + ": one",
+		"ok " + filepath.Join(dir, "b.sen") + ": one",
+	}
+	if strings.Join(points, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected test points\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(points, "\n"))
+	}
+	if plan != "1..3" {
+		t.Errorf("expected a plan of 1..3, got %s", plan)
+	}
+}
@@ -5,6 +5,10 @@ package gtt
 import (
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ohler55/ojg/oj"
 )
@@ -13,11 +17,14 @@ const (
 	aComment  = ""
 	aRequest  = "\x1b[36m"   // dark cyan
 	aResponse = "\x1b[32;1m" // green
+	aDiff     = "\x1b[m"     // always displayed
 	underline = "\x1b[4m"
 	normal    = "\x1b[m" // back to normal
 	// Debug for debug logging.
-	Debug = "\x1b[35m" // dark cyan
-	red   = "\x1b[31m" // red
+	Debug  = "\x1b[35m" // dark cyan
+	red    = "\x1b[31m" // red
+	green  = "\x1b[32m" // green
+	yellow = "\x1b[33m" // yellow
 )
 
 // Runner runs UseCases. It provide focal point for an assembly of use case
@@ -58,24 +65,232 @@ type Runner struct {
 	// UseCases to run.
 	UseCases []*UseCase
 
+	// Setup if not nil is run once before the UseCases. The values it
+	// remembers are copied into the memory of each use case and the
+	// Teardown when they start. If Setup fails the UseCases are skipped.
+	Setup *UseCase
+
+	// Teardown if not nil is always run after the UseCases even if Setup or
+	// one of the UseCases failed.
+	Teardown *UseCase
+
 	// Writer is an alternate io.Writer that will be used in place of writing
 	// to Stdout when logging if not nil.
 	Writer io.Writer
+
+	// Concurrency is the maximum number of use cases to run at the same
+	// time. A value of 0 or 1 runs the use cases one after another. When
+	// running concurrently the log output of each use case is buffered and
+	// written as a block when the use case completes so the output of
+	// different use cases is not interleaved.
+	Concurrency int
+
+	// Continue if true runs all use cases even if some fail. The error
+	// returned from a run then reports the number of failed use cases along
+	// with the Teardown error if the Teardown also failed.
+	Continue bool
+
+	// Reporters are notified of events such as the start and end of use
+	// cases and steps during a run.
+	Reporters []Reporter
+
+	// Manifest if not nil is a persisted operation manifest. Requests then
+	// include only the documentId of an operation in place of the query.
+	// An operation that is not in the manifest results in an error.
+	Manifest *Manifest
+
+	// Client if not nil is the HTTP client used for requests. If the client
+	// has a cookie jar it is used as the cookie jar of every use case in
+	// place of a new or shared jar and cookie expectations, remembered
+	// cookies, and cookie changes all use it. The client jar is never
+	// replaced so a step that clears cookies fails. Otherwise the use case
+	// cookie jar is used.
+	Client *http.Client
+
+	// Transport if not nil is the http.RoundTripper used for requests when
+	// Client is nil. It can be used to inject tracing, stub responses, or
+	// serve requests with an in-process handler. If the transport of the
+	// Client or the Transport is an *http.Transport then the DialContext
+	// and TLSClientConfig are also used for WebSocket connections.
+	Transport http.RoundTripper
+
+	// Handler if not nil is served in-process and all requests, including
+	// WebSocket connections, are sent to it without a network listener. If
+	// Server is empty then a placeholder server URL is used. The Handler is
+	// ignored if Client or Transport is set. The Handler is served for the
+	// duration of Run, RunReport, and RunTests. When a use case or step is
+	// run directly with UseCase.Run or Step.Execute the Handler is served
+	// from the first request until Close is called.
+	Handler http.Handler
+
+	// ShareCookies if true uses one cookie jar for all use cases. Otherwise
+	// each run of a use case starts with an empty cookie jar. ShareCookies
+	// is ignored if the Client has a cookie jar as that jar is already
+	// shared by all use cases.
+	ShareCookies bool
+
+	jar              *sharedJar
+	seed             map[string]interface{}
+	handlerTransport *http.Transport
+	handlerSrv       *http.Server
+	mu               sync.Mutex
+	reportMu         sync.Mutex
 }
 
-// Run the usecases.
+// Run the usecases. Unless running concurrently the use cases are run in
+// order and the run stops on the first failure. When running concurrently no
+// new use cases are started after a failure and the error from the first
+// failed use case in the UseCases list is returned. If Continue is true all
+// the use cases are run regardless of failures. The Setup, if set, is run
+// first and the Teardown, if set, is always run last.
 func (r *Runner) Run() (err error) {
-	for _, uc := range r.UseCases {
-		if err = uc.Run(r); err != nil {
-			break
+	_, err = r.RunReport()
+	return
+}
+
+// RunReport runs the use cases the same as Run but also returns a Report
+// that describes the outcome of each use case and step. Use cases that were
+// not run due to an earlier failure are included in the report with a Skip
+// status.
+func (r *Runner) RunReport() (rep *Report, err error) {
+	rep = &Report{
+		Start:    time.Now(),
+		UseCases: make([]*UseCaseResult, len(r.UseCases)),
+	}
+	if r.Handler != nil && r.Client == nil && r.Transport == nil {
+		defer r.serveHandler()()
+	}
+	r.seed = nil
+	defer func() { r.seed = nil }()
+
+	r.emit(&Event{Kind: RunStart, Count: len(r.UseCases)})
+	if r.Setup != nil {
+		rep.Setup = r.Setup.run(r)
+		err = rep.Setup.err
+		r.seed = rep.Setup.Memory
+	}
+	switch {
+	case err != nil:
+		for i, uc := range r.UseCases {
+			rep.UseCases[i] = r.skip(uc)
+		}
+	case r.Concurrency <= 1:
+		for i, uc := range r.UseCases {
+			if err != nil && !r.Continue {
+				rep.UseCases[i] = r.skip(uc)
+				continue
+			}
+			rep.UseCases[i] = uc.run(r)
+			if err == nil {
+				err = rep.UseCases[i].err
+			}
+		}
+	default:
+		r.runConcurrent(rep)
+		for _, ucr := range rep.UseCases {
+			if ucr.err != nil {
+				err = ucr.err
+				break
+			}
 		}
 	}
+	if r.Continue {
+		if _, fail, _ := rep.Counts(); 0 < fail {
+			err = fmt.Errorf("%d of %d use cases failed", fail, len(rep.UseCases))
+		}
+	}
+	if r.Teardown != nil {
+		rep.Teardown = r.Teardown.run(r)
+		switch {
+		case rep.Teardown.err == nil:
+		case err == nil:
+			err = rep.Teardown.err
+		case r.Continue:
+			err = fmt.Errorf("%s and the teardown failed. %w", err, rep.Teardown.err)
+		}
+	}
+	rep.Duration = time.Since(rep.Start)
+	ev := Event{Kind: RunEnd, Status: Pass, Duration: rep.Duration}
+	if err != nil {
+		ev.Status = Fail
+		ev.Error = err.Error()
+	}
+	r.emit(&ev)
 	if r.ShowComments || r.ShowRequests || r.ShowResponses {
-		fmt.Println()
+		r.write("\n")
 	}
 	return
 }
 
+func (r *Runner) runConcurrent(rep *Report) {
+	queue := make(chan int, len(r.UseCases))
+	for i := range r.UseCases {
+		queue <- i
+	}
+	close(queue)
+
+	var failed bool
+	var wg sync.WaitGroup
+	for w := 0; w < r.Concurrency && w < len(r.UseCases); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				uc := r.UseCases[i]
+				r.mu.Lock()
+				stop := failed
+				r.mu.Unlock()
+				if stop {
+					rep.UseCases[i] = r.skip(uc)
+					continue
+				}
+				uc.out = &strings.Builder{}
+				ucr := uc.run(r)
+				rep.UseCases[i] = ucr
+				r.mu.Lock()
+				if ucr.err != nil && !r.Continue {
+					failed = true
+				}
+				r.mu.Unlock()
+				r.write(uc.out.String())
+				uc.out = nil
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Runner) skip(uc *UseCase) *UseCaseResult {
+	r.emit(&Event{Kind: UseCaseEnd, Filepath: uc.Filepath, Status: Skip})
+	ucr := &UseCaseResult{Filepath: uc.Filepath, Status: Skip}
+	for _, step := range uc.Steps {
+		ucr.Steps = append(ucr.Steps, &StepResult{Label: step.Label, Status: Skip})
+	}
+	return ucr
+}
+
+// emit an event to each of the reporters.
+func (r *Runner) emit(ev *Event) {
+	if len(r.Reporters) == 0 {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	r.reportMu.Lock()
+	defer r.reportMu.Unlock()
+	for _, rp := range r.Reporters {
+		rp.Report(ev)
+	}
+}
+
+// Summarize writes a summary table of the report to the runner output. The
+// summary includes the counts of passed, failed, and skipped use cases and
+// steps along with the labels of the steps that failed.
+func (r *Runner) Summarize(rep *Report) {
+	r.write(rep.summary(!r.NoColor))
+}
+
 // String representation of the runner.
 func (r *Runner) String() string {
 	return string(r.JSON())
@@ -106,6 +321,21 @@ func (r *Runner) Native() interface{} {
 		"showResponses": r.ShowResponses,
 		"noColor":       r.NoColor,
 		"indent":        r.Indent,
+		"concurrency":   r.Concurrency,
+		"continue":      r.Continue,
+		"shareCookies":  r.ShareCookies,
+	}
+	if r.Handler != nil {
+		native["handler"] = fmt.Sprintf("%T", r.Handler)
+	}
+	if r.Manifest != nil {
+		native["manifest"] = len(r.Manifest.ops)
+	}
+	if r.Setup != nil {
+		native["setup"] = r.Setup.Native()
+	}
+	if r.Teardown != nil {
+		native["teardown"] = r.Teardown.Native()
 	}
 	return native
 }
@@ -115,29 +345,56 @@ func (r *Runner) Simplify() interface{} {
 	return r.Native()
 }
 
+// cookieJar returns the cookie jar shared by all use cases.
+func (r *Runner) cookieJar() http.CookieJar {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.jar == nil {
+		r.jar = &sharedJar{jar: newJar()}
+	}
+	return r.jar
+}
+
 // Log output for one of the categories.
 func (r *Runner) Log(color string, format string, args ...interface{}) {
+	if str, ok := r.format(color, format, args...); ok {
+		r.write(str)
+	}
+}
+
+// format a log entry. If the category is not being displayed false is
+// returned.
+func (r *Runner) format(color string, format string, args ...interface{}) (string, bool) {
 	switch color {
 	case aComment:
 		if !r.ShowComments {
-			return
+			return "", false
 		}
 	case aRequest:
 		if !r.ShowRequests {
-			return
+			return "", false
 		}
 	case aResponse:
 		if !r.ShowResponses {
-			return
+			return "", false
 		}
 	}
 	format += "\n"
 	if !r.NoColor && color != aComment {
 		format = color + format + normal
 	}
+	return fmt.Sprintf(format, args...), true
+}
+
+func (r *Runner) write(str string) {
+	if len(str) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.Writer != nil {
-		_, _ = r.Writer.Write([]byte(fmt.Sprintf(format, args...)))
+		_, _ = r.Writer.Write([]byte(str))
 	} else {
-		fmt.Printf(format, args...)
+		fmt.Print(str)
 	}
 }
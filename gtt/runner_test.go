@@ -0,0 +1,69 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunnerContinue(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"pass.sen":     `{steps: [{label: passing content: "{a}" expect: {data: {a: 1}}}]}`,
+		"fail.sen":     `{steps: [{label: failing content: "{a}" expect: {data: {a: 2}}}]}`,
+		"setup.sen":    `{steps: [{label: bad-setup content: "{a}" expect: {data: {a: 3}}}]}`,
+		"teardown.sen": `{steps: [{label: bad-teardown content: "{a}" expect: {data: {a: 4}}}]}`,
+	})
+	for _, c := range []struct {
+		name     string
+		cont     bool
+		setup    bool
+		teardown bool
+		cases    []string
+		expect   []string
+	}{
+		{name: "continue", cont: true, cases: []string{"fail.sen", "pass.sen", "fail.sen"}, expect: []string{"2 of 3 use cases failed"}},
+		{name: "setup", cont: true, setup: true, cases: []string{"fail.sen", "pass.sen"}, expect: []string{"bad-setup"}},
+		{name: "teardown", cont: true, teardown: true, cases: []string{"pass.sen"}, expect: []string{"bad-teardown"}},
+		{
+			name:     "continue teardown",
+			cont:     true,
+			teardown: true,
+			cases:    []string{"fail.sen", "pass.sen"},
+			expect:   []string{"1 of 2 use cases failed", "bad-teardown"},
+		},
+		{name: "stop teardown", teardown: true, cases: []string{"fail.sen", "pass.sen"}, expect: []string{"failing"}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			r := Runner{
+				Server:   ts.URL,
+				Continue: c.cont,
+				Writer:   ioutil.Discard,
+				UseCases: loadUseCases(t, dir, c.cases...),
+			}
+			if c.setup {
+				r.Setup = loadUseCases(t, dir, "setup.sen")[0]
+			}
+			if c.teardown {
+				r.Teardown = loadUseCases(t, dir, "teardown.sen")[0]
+			}
+			err := r.Run()
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, x := range c.expect {
+				if !strings.Contains(err.Error(), x) {
+					t.Errorf("expected %q in the error %q", x, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+const sseTokenHeader = "X-GraphQL-Event-Stream-Token"
+
+// sseEvent is a single server-sent event.
+type sseEvent struct {
+	event string
+	data  string
+}
+
+// sseReader reads server-sent events from a text/event-stream.
+type sseReader struct {
+	rd *bufio.Reader
+}
+
+// read the next event. Comments and fields other than event and data are
+// ignored. An event that is not followed by a blank line before the end of
+// the stream is still returned.
+func (sr *sseReader) read() (*sseEvent, error) {
+	var ev sseEvent
+	var data []string
+	for {
+		line, err := sr.rd.ReadString('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF && (0 < len(ev.event) || 0 < len(data)) {
+				ev.data = strings.Join(data, "\n")
+				return &ev, nil
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			if 0 < len(ev.event) || 0 < len(data) {
+				ev.data = strings.Join(data, "\n")
+				return &ev, nil
+			}
+			continue
+		}
+		if line[0] == ':' {
+			continue
+		}
+		field := line
+		value := ""
+		if i := strings.IndexByte(line, ':'); 0 < i {
+			field = line[:i]
+			value = strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			ev.event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
+
+// collectSSE collects events using the graphql-sse protocol in either the
+// distinct connections mode or the single connection mode.
+func (sub *Subscription) collectSSE(
+	ctx context.Context,
+	client *http.Client,
+	u string,
+	protocol string,
+	header http.Header,
+	payload map[string]interface{},
+	st *stream) (err error) {
+
+	if protocol == SingleSSE {
+		return sub.collectSingleSSE(ctx, client, u, header, payload, st)
+	}
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, "POST", u, strings.NewReader(oj.JSON(payload))); err != nil {
+		return
+	}
+	copyHeader(req.Header, header)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	var res *http.Response
+	if res, err = client.Do(req); err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("event stream request failed with status %d. %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	st.established()
+	sr := sseReader{rd: bufio.NewReader(res.Body)}
+	for sub.Count == 0 || st.count() < sub.Count {
+		var ev *sseEvent
+		if ev, err = sr.read(); err != nil {
+			return sseEnd(ctx, err)
+		}
+		switch ev.event {
+		case "next":
+			var v interface{}
+			if v, err = oj.ParseString(ev.data); err != nil {
+				return
+			}
+			st.add(v)
+		case "complete":
+			return
+		}
+	}
+	return
+}
+
+// collectSingleSSE uses the single connection mode of the graphql-sse
+// protocol. A reservation is made, the event stream is opened, and then the
+// operation is submitted.
+func (sub *Subscription) collectSingleSSE(
+	ctx context.Context,
+	client *http.Client,
+	u string,
+	header http.Header,
+	payload map[string]interface{},
+	st *stream) (err error) {
+
+	var token string
+	if token, err = sseReserve(ctx, client, u, header); err != nil {
+		return
+	}
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, "GET", u, nil); err != nil {
+		return
+	}
+	copyHeader(req.Header, header)
+	req.Header.Set(sseTokenHeader, token)
+	req.Header.Set("Accept", "text/event-stream")
+	var res *http.Response
+	if res, err = client.Do(req); err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("event stream request failed with status %d. %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	const opID = "1"
+	op := map[string]interface{}{}
+	for k, v := range payload {
+		op[k] = v
+	}
+	ext := map[string]interface{}{"operationId": opID}
+	if pe, ok := payload["extensions"].(map[string]interface{}); ok {
+		for k, v := range pe {
+			ext[k] = v
+		}
+		ext["operationId"] = opID
+	}
+	op["extensions"] = ext
+	if err = sseSend(ctx, client, "POST", u, header, token, strings.NewReader(oj.JSON(op))); err != nil {
+		return
+	}
+	st.established()
+	sr := sseReader{rd: bufio.NewReader(res.Body)}
+	for sub.Count == 0 || st.count() < sub.Count {
+		var ev *sseEvent
+		if ev, err = sr.read(); err != nil {
+			return sseEnd(ctx, err)
+		}
+		var v interface{}
+		if 0 < len(ev.data) {
+			if v, err = oj.ParseString(ev.data); err != nil {
+				return
+			}
+		}
+		msg, _ := v.(map[string]interface{})
+		if id, _ := msg["id"].(string); id != opID {
+			continue
+		}
+		switch ev.event {
+		case "next":
+			st.add(msg["payload"])
+		case "complete":
+			return
+		}
+	}
+	// Enough events were collected so stop the operation.
+	_ = sseSend(ctx, client, "DELETE", u+sseQuerySep(u)+"operationId="+opID, header, token, nil)
+
+	return
+}
+
+// sseReserve makes a single connection mode reservation and returns the
+// token.
+func sseReserve(ctx context.Context, client *http.Client, u string, header http.Header) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "PUT", u, nil)
+	if err != nil {
+		return "", err
+	}
+	copyHeader(req.Header, header)
+	var res *http.Response
+	if res, err = client.Do(req); err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("event stream reservation failed with status %d. %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// sseSend sends a request with the stream token and fails on a non-2xx
+// response.
+func sseSend(ctx context.Context, client *http.Client, method, u string, header http.Header, token string, body io.Reader) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	copyHeader(req.Header, header)
+	req.Header.Set(sseTokenHeader, token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	var res *http.Response
+	if res, err = client.Do(req); err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	content, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode < 200 || 300 <= res.StatusCode {
+		return fmt.Errorf("event stream %s failed with status %d. %s", method, res.StatusCode, strings.TrimSpace(string(content)))
+	}
+	return nil
+}
+
+// sseEnd returns nil if the error indicates the stream ended, the timeout
+// was reached, or collection was stopped.
+func sseEnd(ctx context.Context, err error) error {
+	if err == io.EOF || ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err) {
+		return nil
+	}
+	return err
+}
+
+func sseQuerySep(u string) string {
+	if strings.ContainsRune(u, '?') {
+		return "&"
+	}
+	return "?"
+}
+
+func copyHeader(to, from http.Header) {
+	for k, v := range from {
+		to[k] = v
+	}
+}
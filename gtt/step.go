@@ -3,11 +3,13 @@
 package gtt
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -34,9 +36,11 @@ type Step struct {
 	// unless it starts with a '/' character.
 	Path string
 
-	// Content is the content when using a POST request. If the Content is not
-	// empty then a POST is used. If it is empty then a GET request is made to
-	// the server. The string should be either GraphQL or the HTTP GET format.
+	// Content is the GraphQL document of the request. If the Content is not
+	// empty then a POST is used unless the Method is "GET" in which case the
+	// Content is sent as the query URL parameter. If it is empty then a GET
+	// request is made to the server with the Path, which may include the
+	// query in the HTTP GET format.
 	Content string
 
 	// UseJSON if true and a POST request then the JSON format will be used
@@ -49,7 +53,10 @@ type Step struct {
 	// what key to store that value in. In the map, the keys are the keys for
 	// the memory cache while the Remember map values are the path to the
 	// value to remember. The path can be a simple dot delimited path or a
-	// full JSONPath starting with a @ or $ character.
+	// full JSONPath starting with a @ or $ character. A path that starts with
+	// "header:" followed by a header name remembers the value of a response
+	// header and a path that starts with "cookie:" followed by a cookie name
+	// remembers the value of a cookie.
 	Remember map[string]string
 
 	// Op is the operation to include in either the URL query or as a value
@@ -86,6 +93,60 @@ type Step struct {
 	//   4) Maps and arrays are followed recursively.
 	Expect interface{}
 
+	// Incremental if true requests incremental delivery of @defer and
+	// @stream results by setting the Accept header to allow multipart/mixed
+	// responses. A multipart/mixed response is merged into a single result
+	// whether this is set or not.
+	Incremental bool
+
+	// Patches is the expected list of patches, in order, of an incremental
+	// delivery response. The patches are the elements of the "incremental"
+	// arrays of the subsequent payloads. The same comparison rules as Expect
+	// are used.
+	Patches []interface{}
+
+	// Files are the files to upload using the GraphQL multipart request
+	// format. The keys are the paths to the variables, such as
+	// "variables.file" or "files.0", that the files are mapped to. If not
+	// empty the request is sent as multipart/form-data with the Content as
+	// the query.
+	Files map[string]*Upload
+
+	// Batch if not empty is a list of operations sent as a JSON array in a
+	// single POST request. The response is expected to be an array with a
+	// result for each operation.
+	Batch []*Operation
+
+	// Method if "GET" sends the Content as the query URL parameter of a GET
+	// request along with the variables, operationName, and extensions, all
+	// URL encoded. If empty or "POST" a POST is used if there is Content.
+	Method string
+
+	// Extensions are included in the extensions member of a JSON request or
+	// in the extensions URL parameter of a GET request.
+	Extensions map[string]interface{}
+
+	// APQ if not nil indicates the Automatic Persisted Queries protocol is
+	// used for the request.
+	APQ *APQ
+
+	// ExpectHeaders are the expected response headers. The values follow
+	// the same rules as Expect values and a header matches if any of its
+	// values match. Numbers and booleans are compared as strings. An array
+	// matches a header with a value for each element in the same order. A
+	// nil value indicates the header must not be present.
+	ExpectHeaders map[string]interface{}
+
+	// ExpectCookies are the expected cookies in the cookie jar of the use
+	// case after the request. The values follow the same rules as Expect
+	// values and a nil value indicates the cookie must not be present.
+	ExpectCookies map[string]interface{}
+
+	// Cookies if not nil are changes to the cookie jar made before the
+	// request. If the step has no Content, Path, or Batch then no request is
+	// made and only the cookie jar is changed and checked.
+	Cookies *Cookies
+
 	// Always indicates the step should always be performed even if the test
 	// has failed. Usually used to assure cleanup steps are executed.
 	Always bool
@@ -99,6 +160,27 @@ type Step struct {
 
 	// Status expected in the response.
 	Status int
+
+	// Subscription if not nil indicates the step is a GraphQL subscription
+	// over a WebSocket. The events received are collected into an array that
+	// is matched against the Expect value.
+	Subscription *Subscription
+
+	// Await if not empty is the name of a background subscription started
+	// by an earlier step. The step waits for the subscription to complete or
+	// for the step timeout and then matches the events collected against
+	// the Expect value.
+	Await string
+
+	// Position of the step in the use case file if the step was read from a
+	// file.
+	Position *Position
+
+	expectSrc  *srcNode
+	patchesSrc *srcNode
+	apqSrc     *srcNode
+	headersSrc *srcNode
+	cookiesSrc *srcNode
 }
 
 // Set the members of the step based on the data provided.
@@ -113,6 +195,15 @@ func (s *Step) Set(data interface{}) (err error) {
 	s.Op, _ = m["op"].(string)
 	s.UseJSON, _ = m["json"].(bool)
 	s.Always, _ = m["always"].(bool)
+	s.Await, _ = m["await"].(string)
+	s.Incremental, _ = m["incremental"].(bool)
+	s.Method, _ = m["method"].(string)
+	s.Method = strings.ToUpper(s.Method)
+	switch s.Method {
+	case "", "GET", "POST":
+	default:
+		return fmt.Errorf("%s is not a valid method for step %s", s.Method, s.Label)
+	}
 	switch n := m["status"].(type) {
 	case float64:
 		s.Status = int(n)
@@ -135,8 +226,73 @@ func (s *Step) Set(data interface{}) (err error) {
 			return
 		}
 	}
+	if v := m["subscribe"]; v != nil {
+		s.Subscription = &Subscription{}
+		if err = s.Subscription.Set(v); err != nil {
+			return
+		}
+	}
+	if v := m["batch"]; v != nil {
+		list, _ := v.([]interface{})
+		if list == nil {
+			return fmt.Errorf("%T is not a valid type for a batch", v)
+		}
+		s.Batch = []*Operation{}
+		for _, ov := range list {
+			o := Operation{}
+			if err = o.Set(ov); err != nil {
+				return
+			}
+			s.Batch = append(s.Batch, &o)
+		}
+	}
+	if v := m["apq"]; v != nil {
+		s.APQ = &APQ{}
+		if err = s.APQ.Set(v); err != nil {
+			return
+		}
+	}
 	if v := m["expect"]; v != nil {
-		if s.Expect, err = asMapOrString(v); err != nil {
+		if list, ok := v.([]interface{}); ok && (s.Subscription != nil || 0 < len(s.Await) || s.Batch != nil) {
+			// Subscription events are expected to be an array and not a
+			// multi-line string.
+			s.Expect = list
+		} else if s.Expect, err = asMapOrString(v); err != nil {
+			return
+		}
+	}
+	if v := m["patches"]; v != nil {
+		if s.Patches, ok = v.([]interface{}); !ok {
+			return fmt.Errorf("%T is not a valid type for patches", v)
+		}
+	}
+	if v := m["files"]; v != nil {
+		fm, _ := v.(map[string]interface{})
+		if fm == nil {
+			return fmt.Errorf("%T is not a valid type for files", v)
+		}
+		s.Files = map[string]*Upload{}
+		for k, fv := range fm {
+			up := Upload{}
+			if err = up.Set(fv); err != nil {
+				return
+			}
+			s.Files[k] = &up
+		}
+	}
+	if v := m["expectHeaders"]; v != nil {
+		if s.ExpectHeaders, ok = v.(map[string]interface{}); !ok {
+			return fmt.Errorf("%T is not a valid type for expectHeaders", v)
+		}
+	}
+	if v := m["expectCookies"]; v != nil {
+		if s.ExpectCookies, ok = v.(map[string]interface{}); !ok {
+			return fmt.Errorf("%T is not a valid type for expectCookies", v)
+		}
+	}
+	if v := m["cookies"]; v != nil {
+		s.Cookies = &Cookies{}
+		if err = s.Cookies.Set(v); err != nil {
 			return
 		}
 	}
@@ -155,6 +311,11 @@ func (s *Step) Set(data interface{}) (err error) {
 			return fmt.Errorf("%T is not a valid type for a map[string]interface{}", v)
 		}
 	}
+	if v := m["extensions"]; v != nil {
+		if s.Extensions, ok = v.(map[string]interface{}); !ok {
+			return fmt.Errorf("%T is not a valid type for a map[string]interface{}", v)
+		}
+	}
 	if v := m["headers"]; v != nil {
 		if s.Headers, err = asMapStrStr(v); err != nil {
 			return
@@ -181,7 +342,51 @@ func (s *Step) Native() interface{} {
 	if s.UseJSON {
 		native["json"] = s.UseJSON
 	}
+	if 0 < len(s.Method) {
+		native["method"] = s.Method
+	}
+	if s.Extensions != nil {
+		native["extensions"] = s.Extensions
+	}
+	if s.Subscription != nil {
+		native["subscribe"] = s.Subscription.Native()
+	}
+	if 0 < len(s.Await) {
+		native["await"] = s.Await
+	}
+	if s.Incremental {
+		native["incremental"] = s.Incremental
+	}
 	addAny(native, "expect", s.Expect)
+	if s.ExpectHeaders != nil {
+		native["expectHeaders"] = s.ExpectHeaders
+	}
+	if s.ExpectCookies != nil {
+		native["expectCookies"] = s.ExpectCookies
+	}
+	if s.Cookies != nil {
+		native["cookies"] = s.Cookies.Native()
+	}
+	if s.Patches != nil {
+		native["patches"] = s.Patches
+	}
+	if 0 < len(s.Batch) {
+		batch := make([]interface{}, 0, len(s.Batch))
+		for _, o := range s.Batch {
+			batch = append(batch, o.Native())
+		}
+		native["batch"] = batch
+	}
+	if s.APQ != nil {
+		native["apq"] = s.APQ.Native()
+	}
+	if 0 < len(s.Files) {
+		files := map[string]interface{}{}
+		for k, up := range s.Files {
+			files[k] = up.Native()
+		}
+		native["files"] = files
+	}
 	addNotNil(native, "remember", s.Remember)
 	addNotNil(native, "vars", s.Vars)
 	addNotNil(native, "sortBy", s.SortBy)
@@ -192,7 +397,11 @@ func (s *Step) Native() interface{} {
 // Execute the step using the information in the provided use case such as
 // remembered values and base URL.
 func (s *Step) Execute(uc *UseCase) error {
-	if len(uc.runner.Server) == 0 {
+	return s.execute(uc, &StepResult{Label: s.Label})
+}
+
+func (s *Step) execute(uc *UseCase, sr *StepResult) error {
+	if len(uc.runner.Server) == 0 && uc.runner.Handler == nil {
 		return fmt.Errorf("server not specified")
 	}
 	var comment []string
@@ -203,49 +412,93 @@ func (s *Step) Execute(uc *UseCase) error {
 		comment = append(comment, s.Comment)
 	}
 	if 0 < len(comment) {
-		uc.runner.Log(aComment, strings.Join(comment, ": "))
+		uc.log(aComment, strings.Join(comment, ": "))
+	}
+	if 0 < len(s.Await) {
+		return s.await(uc, sr)
 	}
 	u := uc.runner.Server
-	sep := '?'
+	if len(u) == 0 {
+		u = handlerServer
+	}
 	if 0 < len(s.Path) {
 		if s.Path[0] != '/' { // relative path
 			u += uc.runner.Base
 		}
 		u += s.Path
-		sep = '&'
 	} else {
 		u += uc.runner.Base
 	}
-	vars := map[string]interface{}{}
-	for k, v := range s.Vars {
-		if s, _ := v.(string); 0 < len(s) && s[0] == '$' {
-			v = uc.memory[s[1:]]
+	vars := uc.resolveVars(s.Vars)
+	if s.Cookies != nil {
+		if err := s.Cookies.apply(uc, u); err != nil {
+			return err
+		}
+		if len(s.Content) == 0 && len(s.Path) == 0 && len(s.Batch) == 0 {
+			sr.URL = u
+			s.rememberCookies(uc, u)
+			return s.checkCookies(uc, u)
 		}
-		vars[k] = v
 	}
-	if s.UseJSON && len(s.Content) == 0 {
+	if s.Subscription != nil {
+		return s.subscribe(uc, sr, u, vars)
+	}
+	if s.APQ != nil {
+		return s.persisted(uc, sr, u, vars)
+	}
+	if s.Method == "GET" {
+		return s.get(uc, sr, u, vars)
+	}
+	if s.UseJSON && len(s.Content) == 0 && len(s.Batch) == 0 {
 		return fmt.Errorf("if using JSON the content can not be empty in step %s", s.Label)
 	}
-	if !s.UseJSON {
+	// A persisted operation manifest requires the JSON format.
+	useJSON := s.UseJSON || (uc.runner.Manifest != nil && 0 < len(s.Content))
+	if !useJSON && len(s.Files) == 0 && len(s.Batch) == 0 {
 		// Put the variables in the URL as a JSON string if not empty.
+		params := url.Values{}
 		if 0 < len(vars) {
-			u = fmt.Sprintf("%s%cvariables=%s", u, sep, oj.JSON(vars))
-			sep = '&'
+			params.Set("variables", oj.JSON(vars))
 		}
 		if 0 < len(s.Op) {
-			u = fmt.Sprintf("%s%coperationName=%s", u, sep, s.Op)
+			params.Set("operationName", s.Op)
+		}
+		if 0 < len(s.Extensions) {
+			params.Set("extensions", oj.JSON(s.Extensions))
 		}
+		u = addQuery(u, params)
 	}
 	contentType := "application/graphql"
 	var content io.Reader
 	contentStr := s.Content
-	if 0 < len(s.Content) { // POST
+	if 0 < len(s.Batch) {
+		contentType = "application/json"
+		list, err := s.batchContent(uc)
+		if err != nil {
+			return err
+		}
+		j := oj.JSON(list)
+		content = strings.NewReader(j)
+		contentStr = uc.replaceVars(j)
+	} else if 0 < len(s.Files) {
+		if len(s.Content) == 0 {
+			return fmt.Errorf("an upload step must have content in step %s", s.Label)
+		}
+		var body []byte
+		var err error
+		if contentType, body, contentStr, err = s.uploadContent(uc, vars); err != nil {
+			return err
+		}
+		content = bytes.NewReader(body)
+		contentStr = uc.replaceVars(contentStr)
+	} else if 0 < len(s.Content) { // POST
 		// If the JSON then wrap and populate operationName and variables
 		// otherwise add the variables and op to the URL query parameters.
-		if s.UseJSON {
+		if useJSON {
 			contentType = "application/json"
-			wrap := map[string]interface{}{
-				"query": s.Content,
+			wrap := map[string]interface{}{}
+			if err := uc.setDocument(wrap, s.Content); err != nil {
+				return err
 			}
 			if 0 < len(vars) {
 				wrap["variables"] = vars
@@ -253,6 +506,9 @@ func (s *Step) Execute(uc *UseCase) error {
 			if 0 < len(s.Op) {
 				wrap["operationName"] = s.Op
 			}
+			if 0 < len(s.Extensions) {
+				wrap["extensions"] = s.Extensions
+			}
 			j := oj.JSON(wrap)
 			content = strings.NewReader(j)
 			contentStr = uc.replaceVars(j)
@@ -260,54 +516,164 @@ func (s *Step) Execute(uc *UseCase) error {
 			content = strings.NewReader(s.Content)
 		}
 	}
-	var res *http.Response
-	var err error
+	method := "GET"
+	if content != nil {
+		method = "POST"
+	}
+	res, body, err := s.send(uc, sr, method, u, contentType, content, contentStr)
+	if err != nil {
+		return err
+	}
+	return s.checkResponse(uc, sr, res, body)
+}
+
+// get sends the content as a GET request with the query, variables,
+// operationName, and extensions encoded as URL query parameters.
+func (s *Step) get(uc *UseCase, sr *StepResult, u string, vars map[string]interface{}) error {
+	params := url.Values{}
+	if 0 < len(s.Content) {
+		doc := map[string]interface{}{}
+		if err := uc.setDocument(doc, s.Content); err != nil {
+			return err
+		}
+		for k, v := range doc {
+			params.Set(k, v.(string))
+		}
+	}
+	if 0 < len(vars) {
+		params.Set("variables", oj.JSON(vars))
+	}
+	if 0 < len(s.Op) {
+		params.Set("operationName", s.Op)
+	}
+	if 0 < len(s.Extensions) {
+		params.Set("extensions", oj.JSON(s.Extensions))
+	}
+	res, body, err := s.send(uc, sr, "GET", addQuery(u, params), "", nil, "")
+	if err != nil {
+		return err
+	}
+	return s.checkResponse(uc, sr, res, body)
+}
+
+// addQuery adds the encoded parameters to the URL query.
+func addQuery(u string, params url.Values) string {
+	if len(params) == 0 {
+		return u
+	}
+	if strings.ContainsRune(u, '?') {
+		return u + "&" + params.Encode()
+	}
+	return u + "?" + params.Encode()
+}
 
-	uc.runner.Log(aRequest, "URL: %s\nContent-Type: %s\n%s", u, contentType, contentStr)
+// send a request and read the response. The step result is updated with
+// the request and response.
+func (s *Step) send(
+	uc *UseCase,
+	sr *StepResult,
+	method string,
+	u string,
+	contentType string,
+	content io.Reader,
+	contentStr string) (res *http.Response, body []byte, err error) {
+
+	sr.Method = method
+	sr.URL = u
+	sr.Request = contentStr
+	uc.log(aRequest, "URL: %s\nContent-Type: %s\n%s", u, contentType, contentStr)
 	var req *http.Request
 	cx, cf := context.WithTimeout(context.Background(), time.Second*time.Duration(s.Timeout))
 	defer cf()
 
-	if content == nil {
-		if req, err = http.NewRequestWithContext(cx, "GET", u, nil); err != nil {
-			return err
-		}
-	} else {
-		if req, err = http.NewRequestWithContext(cx, "POST", u, content); err != nil {
-			return err
-		}
+	if req, err = http.NewRequestWithContext(cx, method, u, content); err != nil {
+		return
+	}
+	if content != nil {
 		req.Header.Add("Content-Type", contentType)
 	}
 	for k, str := range s.Headers {
 		str = uc.replaceVars(str)
 		req.Header.Add(k, str)
 	}
-	if res, err = http.DefaultClient.Do(req); err != nil {
-		return err
+	if s.Incremental && len(req.Header.Get("Accept")) == 0 {
+		req.Header.Set("Accept", incrementalAccept)
+	}
+	uc.emit(s, &Event{Kind: RequestSent, Method: method, URL: u, Content: contentStr})
+	if res, err = uc.client().Do(req); err != nil {
+		return
 	}
 	defer res.Body.Close()
+	sr.StatusCode = res.StatusCode
+	body, _ = ioutil.ReadAll(res.Body)
+	sr.Response = string(body)
+	uc.emit(s, &Event{Kind: ResponseReceived, StatusCode: res.StatusCode, Content: sr.Response})
+
+	return
+}
 
+// checkResponse checks the status and content of a response against the
+// expected values.
+func (s *Step) checkResponse(uc *UseCase, sr *StepResult, res *http.Response, body []byte) error {
 	if 0 < s.Status && s.Status != res.StatusCode {
 		return fmt.Errorf("status code mismatch. Expected %d, received %d", s.Status, res.StatusCode)
 	}
+	if err := s.checkHeaders(uc, res.Header); err != nil {
+		return err
+	}
+	s.rememberHeaders(uc, res.Header)
+	if err := s.checkCookies(uc, sr.URL); err != nil {
+		return err
+	}
+	s.rememberCookies(uc, sr.URL)
+	if boundary, ok := isMultipart(res.Header.Get("Content-Type")); ok {
+		return s.expectIncremental(boundary, body, uc, sr)
+	}
 	if s.Expect == nil {
 		return nil
 	}
-	body, _ := ioutil.ReadAll(res.Body)
-
 	if xstr, ok := s.Expect.(string); ok {
-		return s.expectString(xstr, string(body), uc.runner)
+		return s.expectString(xstr, string(body), uc)
 	}
-	return s.expectJSON(res.StatusCode, body, uc)
+	return s.expectJSON(res.StatusCode, body, uc, sr)
 }
 
-func (s *Step) expectJSON(status int, actual []byte, uc *UseCase) (err error) {
+func (s *Step) expectJSON(status int, actual []byte, uc *UseCase, sr *StepResult) (err error) {
 	var p sen.Parser
 	var result interface{}
 	if result, err = p.Parse(actual); err != nil {
-		uc.runner.Log(aResponse, "[%d] %s", status, string(actual))
+		uc.log(aResponse, "[%d] %s", status, string(actual))
+		return err
+	}
+	return s.expectResult(result, uc, sr)
+}
+
+// expectIncremental merges an incremental delivery response and checks the
+// merged result and the patches.
+func (s *Step) expectIncremental(boundary string, body []byte, uc *UseCase, sr *StepResult) (err error) {
+	if s.Expect == nil && s.Patches == nil {
+		return nil
+	}
+	result, patches, err := readIncremental(boundary, body)
+	if err != nil {
+		uc.log(aResponse, "[%d] %s", sr.StatusCode, string(body))
 		return err
 	}
+	if s.Patches != nil {
+		if uc.runner.ShowResponses {
+			uc.log(aResponse, "Patches: %s", oj.JSON(patches, uc.runner.Indent))
+		}
+		if err = s.compare(uc, "patches", patches, s.Patches, s.patchesSrc, sr); err != nil {
+			return
+		}
+	}
+	if s.Expect == nil {
+		return nil
+	}
+	return s.expectResult(result, uc, sr)
+}
+
+func (s *Step) expectResult(result interface{}, uc *UseCase, sr *StepResult) (err error) {
 	for path, key := range s.SortBy {
 		s.sortResult(result, strings.Split(path, "."), key)
 	}
@@ -326,10 +692,10 @@ func (s *Step) expectJSON(status int, actual []byte, uc *UseCase) (err error) {
 				out = oj.JSON(result, uc.runner.Indent)
 			}
 		}
-		uc.runner.Log(aResponse, "%s", out)
+		uc.log(aResponse, "%s", out)
 	}
 	for k, path := range s.Remember {
-		if len(path) == 0 {
+		if len(path) == 0 || strings.HasPrefix(path, headerPrefix) || strings.HasPrefix(path, cookiePrefix) {
 			continue
 		}
 		if path[0] == '@' || path[0] == '$' {
@@ -343,14 +709,15 @@ func (s *Step) expectJSON(status int, actual []byte, uc *UseCase) (err error) {
 		}
 	}
 	if s.Expect != nil {
-		if err = s.check(result); err != nil {
+		if err = s.check(uc, result, sr); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (s *Step) expectString(expect, actual string, r *Runner) (err error) {
+func (s *Step) expectString(expect, actual string, uc *UseCase) (err error) {
+	r := uc.runner
 	var buf strings.Builder
 
 	lines := strings.Split(actual, "\n")
@@ -365,7 +732,7 @@ func (s *Step) expectString(expect, actual string, r *Runner) (err error) {
 			break
 		}
 		aline := lines[i]
-		if path, _, _ := match(aline, xline); path == nil {
+		if matchValue(aline, xline) {
 			buf.WriteString(aline)
 			buf.WriteByte('\n')
 			continue
@@ -401,7 +768,7 @@ func (s *Step) expectString(expect, actual string, r *Runner) (err error) {
 	if !r.NoColor {
 		buf.WriteString(normal)
 	}
-	r.Log(aResponse, "%s", buf.String())
+	uc.log(aResponse, "%s", buf.String())
 
 	return
 }
@@ -449,7 +816,7 @@ func (s *Step) remember(uc *UseCase, result interface{}, rkey string, path []str
 		}
 	case []interface{}:
 		if i, err := strconv.Atoi(path[0]); err == nil && 0 <= i && i < len(tr) {
-			if len(path) == 1 && path[0] == rkey {
+			if len(path) == 1 {
 				uc.memory[rkey] = tr[i]
 				return
 			}
@@ -459,9 +826,44 @@ func (s *Step) remember(uc *UseCase, result interface{}, rkey string, path []str
 	}
 }
 
-func (s *Step) check(result interface{}) error {
-	if loc, av, xv := match(result, s.Expect); loc != nil {
-		return fmt.Errorf("%s result does not match expected at %s. %v != %v", s.Label, strings.Join(loc, "."), av, xv)
+func (s *Step) check(uc *UseCase, result interface{}, sr *StepResult) error {
+	return s.compare(uc, "result", result, s.Expect, s.expectSrc, sr)
+}
+
+// compare the actual value to the expected value. The what argument
+// identifies the value in the error message.
+func (s *Step) compare(uc *UseCase, what string, result, expect interface{}, src *srcNode, sr *StepResult) error {
+	sr.Mismatches = match(result, expect)
+	if len(sr.Mismatches) == 0 {
+		return nil
 	}
-	return nil
+	for _, m := range sr.Mismatches {
+		uc.emit(s, &Event{Kind: MismatchFound, Path: m.Path, JSONPath: m.JSONPath, Expect: m.Expect, Actual: m.Actual})
+	}
+	for _, m := range sr.Mismatches {
+		m.Position = src.find(m.loc)
+	}
+	first := sr.Mismatches[0]
+	sr.Path = first.Path
+	at := first.Path
+	if first.Position != nil {
+		at = fmt.Sprintf("%s (%s)", first.Path, first.Position)
+	}
+	sr.Diff = diff(expect, result, sr.Mismatches)
+	out := strings.TrimSuffix(sr.Diff, "\n")
+	if !uc.runner.NoColor {
+		out = colorDiff(out)
+	}
+	// Diffs from concurrent use cases may be interleaved so each starts with
+	// the step position or use case file and the step label.
+	where := uc.Filepath
+	if s.Position != nil {
+		where = s.Position.String()
+	}
+	uc.log(aDiff, "%s %s %s diff:\n%s", where, s.Label, what, out)
+	if 1 < len(sr.Mismatches) {
+		return fmt.Errorf("%s %s does not match expected at %s. %v != %v (%d mismatches)",
+			s.Label, what, at, first.Actual, first.Expect, len(sr.Mismatches))
+	}
+	return fmt.Errorf("%s %s does not match expected at %s. %v != %v", s.Label, what, at, first.Actual, first.Expect)
 }
@@ -0,0 +1,385 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+const (
+	// TransportWS is the graphql-transport-ws WebSocket subscription
+	// protocol.
+	TransportWS = "graphql-transport-ws"
+	// LegacyWS is the legacy subscriptions-transport-ws WebSocket
+	// subscription protocol which uses the graphql-ws sub-protocol name.
+	LegacyWS = "graphql-ws"
+	// DistinctSSE is the graphql-sse Server-Sent Events protocol in the
+	// distinct connections mode.
+	DistinctSSE = "graphql-sse"
+	// SingleSSE is the graphql-sse Server-Sent Events protocol in the single
+	// connection mode.
+	SingleSSE = "graphql-sse-single"
+)
+
+// Subscription describes how a subscription step connects to the server and
+// how many events are collected. The events received, in the order received,
+// are matched against the Expect value of the step which should be an array.
+type Subscription struct {
+
+	// Protocol is the subscription protocol. Supported values are
+	// "graphql-transport-ws", the default, "graphql-ws" for the legacy
+	// subscriptions-transport-ws protocol, "graphql-sse" for the
+	// graphql-sse distinct connections mode, and "graphql-sse-single" for
+	// the graphql-sse single connection mode.
+	Protocol string
+
+	// Init is the payload sent with the connection_init message.
+	Init map[string]interface{}
+
+	// Count is the number of events to collect. If zero, events are collected
+	// until the server completes the subscription or the step timeout is
+	// reached.
+	Count int
+
+	// Name if not empty runs the subscription in the background so that
+	// other steps can be executed while events are collected. A later step
+	// with an Await of the same name checks the events collected. The
+	// timeout of the subscription step only limits how long to wait for the
+	// subscription to be established. Collection continues until the await
+	// step timeout or the end of the use case.
+	Name string
+}
+
+// Set the members of the subscription based on the data provided. The data
+// can be true to use the defaults or a map.
+func (sub *Subscription) Set(data interface{}) (err error) {
+	switch td := data.(type) {
+	case bool:
+		return nil
+	case map[string]interface{}:
+		sub.Protocol, _ = td["protocol"].(string)
+		sub.Name, _ = td["name"].(string)
+		switch n := td["count"].(type) {
+		case float64:
+			sub.Count = int(n)
+		case int64:
+			sub.Count = int(n)
+		}
+		if v := td["init"]; v != nil {
+			var ok bool
+			if sub.Init, ok = v.(map[string]interface{}); !ok {
+				return fmt.Errorf("%T is not a valid type for a map[string]interface{}", v)
+			}
+		}
+		switch sub.Protocol {
+		case "", TransportWS, LegacyWS, DistinctSSE, SingleSSE:
+		default:
+			return fmt.Errorf("%s is not a supported subscription protocol", sub.Protocol)
+		}
+		return nil
+	}
+	return fmt.Errorf("%T is not a valid type for a subscription", data)
+}
+
+// Native representation of the subscription.
+func (sub *Subscription) Native() interface{} {
+	native := map[string]interface{}{}
+	if 0 < len(sub.Protocol) {
+		native["protocol"] = sub.Protocol
+	}
+	if 0 < sub.Count {
+		native["count"] = sub.Count
+	}
+	if 0 < len(sub.Name) {
+		native["name"] = sub.Name
+	}
+	if sub.Init != nil {
+		native["init"] = sub.Init
+	}
+	return native
+}
+
+// stream collects the events of a subscription. Events are added as they
+// arrive so a stream can be run in the background while other steps are
+// executed.
+type stream struct {
+	mu     sync.Mutex
+	events []interface{}
+	err    error
+	once   sync.Once
+	ready  chan struct{} // closed when the subscription has been established
+	done   chan struct{} // closed when collection has ended
+	cancel context.CancelFunc
+}
+
+func newStream() *stream {
+	return &stream{
+		ready: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+func (st *stream) add(ev interface{}) {
+	st.mu.Lock()
+	st.events = append(st.events, ev)
+	st.mu.Unlock()
+}
+
+func (st *stream) count() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return len(st.events)
+}
+
+func (st *stream) list() []interface{} {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return append([]interface{}{}, st.events...)
+}
+
+func (st *stream) established() {
+	st.once.Do(func() { close(st.ready) })
+}
+
+// stop the stream and wait for the collection to end.
+func (st *stream) stop() {
+	st.cancel()
+	<-st.done
+}
+
+// subscribe executes a subscription step. The events collected are treated
+// as an array result. If the subscription has a name it is run in the
+// background and the step completes once the subscription is established.
+func (s *Step) subscribe(uc *UseCase, sr *StepResult, u string, vars map[string]interface{}) error {
+	if len(s.Content) == 0 {
+		return fmt.Errorf("a subscription step must have content in step %s", s.Label)
+	}
+	payload := map[string]interface{}{}
+	if err := uc.setDocument(payload, s.Content); err != nil {
+		return err
+	}
+	if 0 < len(vars) {
+		payload["variables"] = vars
+	}
+	if 0 < len(s.Op) {
+		payload["operationName"] = s.Op
+	}
+	if 0 < len(s.Extensions) {
+		payload["extensions"] = s.Extensions
+	}
+	protocol := s.Subscription.Protocol
+	if len(protocol) == 0 {
+		protocol = TransportWS
+	}
+	sse := protocol == DistinctSSE || protocol == SingleSSE
+	sr.Method = "POST"
+	if !sse {
+		sr.Method = "GET"
+		switch {
+		case strings.HasPrefix(u, "https"):
+			u = "wss" + u[5:]
+		case strings.HasPrefix(u, "http"):
+			u = "ws" + u[4:]
+		}
+	}
+	sr.URL = u
+	sr.Request = uc.replaceVars(oj.JSON(payload))
+	uc.log(aRequest, "URL: %s\nProtocol: %s\n%s", u, protocol, sr.Request)
+	uc.emit(s, &Event{Kind: RequestSent, Method: sr.Method, URL: u, Content: sr.Request})
+
+	header := http.Header{}
+	for k, str := range s.Headers {
+		header.Add(k, uc.replaceVars(str))
+	}
+	if !sse {
+		// The WebSocket handshake does not use the HTTP client so add the
+		// cookies from the jar.
+		uc.addCookies(header, sr.URL)
+	}
+	st := newStream()
+	timeout := time.Second * time.Duration(s.Timeout)
+	named := 0 < len(s.Subscription.Name)
+	var cx context.Context
+	if named {
+		// A named subscription runs in the background until it is awaited or
+		// the use case ends so it is not limited by the timeout of this
+		// step.
+		cx, st.cancel = context.WithCancel(context.Background())
+	} else {
+		cx, st.cancel = context.WithTimeout(context.Background(), timeout)
+	}
+	go func() {
+		if sse {
+			st.err = s.Subscription.collectSSE(cx, uc.client(), u, protocol, header, payload, st)
+		} else {
+			dial, tc := uc.dialer()
+			st.err = s.Subscription.collectWS(cx, u, protocol, header, payload, st, dial, tc)
+		}
+		st.cancel()
+		close(st.done)
+	}()
+	if named {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		select {
+		case <-st.ready:
+		case <-st.done:
+			// A subscription that was established and then quickly completed
+			// is still registered so the events can be awaited.
+			select {
+			case <-st.ready:
+			default:
+				return st.err
+			}
+		case <-t.C:
+			st.stop()
+			return fmt.Errorf("subscription %s was not established in step %s", s.Subscription.Name, s.Label)
+		}
+		if prev := uc.streams[s.Subscription.Name]; prev != nil {
+			prev.stop()
+		}
+		if uc.streams == nil {
+			uc.streams = map[string]*stream{}
+		}
+		uc.streams[s.Subscription.Name] = st
+		return nil
+	}
+	<-st.done
+
+	return s.expectEvents(uc, sr, st)
+}
+
+// await the completion of a named background subscription and then check
+// the events collected.
+func (s *Step) await(uc *UseCase, sr *StepResult) error {
+	st := uc.streams[s.Await]
+	if st == nil {
+		return fmt.Errorf("no subscription named %s to await in step %s", s.Await, s.Label)
+	}
+	uc.log(aRequest, "Await: %s", s.Await)
+	t := time.NewTimer(time.Second * time.Duration(s.Timeout))
+	select {
+	case <-st.done:
+		t.Stop()
+	case <-t.C:
+		st.stop()
+	}
+	return s.expectEvents(uc, sr, st)
+}
+
+func (s *Step) expectEvents(uc *UseCase, sr *StepResult, st *stream) error {
+	events := st.list()
+	for _, ev := range events {
+		uc.emit(s, &Event{Kind: ResponseReceived, Content: oj.JSON(ev)})
+	}
+	sr.Response = oj.JSON(events)
+	if st.err != nil {
+		return st.err
+	}
+	if s.Expect == nil {
+		return nil
+	}
+	return s.expectResult(events, uc, sr)
+}
+
+// collectWS collects events over a WebSocket connection using either the
+// graphql-transport-ws or the legacy subscriptions-transport-ws protocol.
+func (sub *Subscription) collectWS(
+	ctx context.Context,
+	u string,
+	protocol string,
+	header http.Header,
+	payload map[string]interface{},
+	st *stream,
+	dial dialFunc,
+	tc *tls.Config) (err error) {
+
+	var ws *wsConn
+	if ws, err = dialWebSocket(ctx, u, protocol, header, dial, tc); err != nil {
+		return
+	}
+	defer func() { _ = ws.close() }()
+
+	// Reads do not observe the context so close the connection to stop
+	// collecting if the context is canceled.
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = ws.conn.Close()
+		case <-finished:
+		}
+	}()
+	legacy := protocol == LegacyWS
+	msg := map[string]interface{}{"type": "connection_init"}
+	if sub.Init != nil {
+		msg["payload"] = sub.Init
+	}
+	if err = ws.writeJSON(msg); err != nil {
+		return
+	}
+ack:
+	for {
+		if msg, err = ws.readJSON(); err != nil {
+			return fmt.Errorf("waiting for connection_ack. %w", err)
+		}
+		switch msg["type"] {
+		case "connection_ack":
+			break ack
+		case "connection_error":
+			return fmt.Errorf("connection error. %s", oj.JSON(msg["payload"]))
+		case "ping":
+			if err = ws.writeJSON(map[string]interface{}{"type": "pong"}); err != nil {
+				return
+			}
+		}
+	}
+	start := "subscribe"
+	if legacy {
+		start = "start"
+	}
+	if err = ws.writeJSON(map[string]interface{}{"id": "1", "type": start, "payload": payload}); err != nil {
+		return
+	}
+	st.established()
+	for sub.Count == 0 || st.count() < sub.Count {
+		if msg, err = ws.readJSON(); err != nil {
+			if err == io.EOF || os.IsTimeout(err) || ctx.Err() != nil {
+				err = nil
+			}
+			return
+		}
+		switch msg["type"] {
+		case "next", "data":
+			st.add(msg["payload"])
+		case "error":
+			return fmt.Errorf("subscription error. %s", oj.JSON(msg["payload"]))
+		case "complete":
+			return
+		case "ping":
+			if err = ws.writeJSON(map[string]interface{}{"type": "pong"}); err != nil {
+				return
+			}
+		}
+	}
+	// Enough events were collected so let the server know the subscription
+	// is no longer needed.
+	if legacy {
+		_ = ws.writeJSON(map[string]interface{}{"id": "1", "type": "stop"})
+		_ = ws.writeJSON(map[string]interface{}{"type": "connection_terminate"})
+	} else {
+		_ = ws.writeJSON(map[string]interface{}{"id": "1", "type": "complete"})
+	}
+	return
+}
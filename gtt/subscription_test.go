@@ -0,0 +1,196 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ohler55/ojg/jp"
+	"github.com/ohler55/ojg/oj"
+)
+
+// sseServer streams the events of the graphql-sse distinct connections mode
+// after the delay in the delay query parameter.
+func sseServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay, _ := time.ParseDuration(r.URL.Query().Get("delay"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		for i := 1; i <= 2; i++ {
+			fmt.Fprintf(w, "event: next\ndata: {\"data\":{\"n\":%d}}\n\n", i)
+		}
+		fmt.Fprint(w, "event: complete\n\n")
+		w.(http.Flusher).Flush()
+	}))
+}
+
+func TestSubscriptionNamed(t *testing.T) {
+	ts := sseServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		// The events arrive after the timeout of the subscribe step but
+		// before the timeout of the await step.
+		"slow.sen": `{steps: [
+  {label: start path: "?delay=1500ms" timeout: 1 content: "subscription {n}" subscribe: {protocol: graphql-sse name: slow}}
+  {label: wait await: slow timeout: 5 expect: [{data: {n: 1}} {data: {n: 2}}]}
+]}`,
+		// The subscription completes as soon as it is established.
+		"quick.sen": `{steps: [
+  {label: start path: "?delay=0s" content: "subscription {n}" subscribe: {protocol: graphql-sse name: quick}}
+  {label: wait await: quick expect: [{data: {n: 1}} {data: {n: 2}}]}
+]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "slow.sen", "quick.sen"),
+	}
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSubscriptionAwaitTimeout(t *testing.T) {
+	ts := sseServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"never.sen": `{steps: [
+  {label: start path: "?delay=1h" content: "subscription {n}" subscribe: {protocol: graphql-sse name: never}}
+  {label: wait await: never timeout: 1 expect: []}
+]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "never.sen"),
+	}
+	start := time.Now()
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); 3*time.Second < d {
+		t.Errorf("await did not stop the subscription at the timeout, took %s", d)
+	}
+}
+
+// sseSingleServer serves the graphql-sse single connection mode. Each
+// request is recorded as the method, the stream token, and the operationId
+// query parameter. With the eof query parameter the stream ends after the
+// second event without a blank line following it.
+func sseSingleServer(requests chan string) *httptest.Server {
+	const token = "tok-1"
+	submitted := make(chan string, 1)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- fmt.Sprintf("%s %s %s", r.Method, r.Header.Get(sseTokenHeader), r.URL.Query().Get("operationId"))
+		if r.Method == "PUT" {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(token))
+			return
+		}
+		if r.Header.Get(sseTokenHeader) != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case "POST":
+			body, _ := ioutil.ReadAll(r.Body)
+			op, _ := oj.Parse(body)
+			id, _ := jp.C("extensions").C("operationId").First(op).(string)
+			if query, _ := jp.C("query").First(op).(string); query != "subscription {n}" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			submitted <- id
+			w.WriteHeader(http.StatusAccepted)
+		case "GET":
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			var id string
+			select {
+			case id = <-submitted:
+			case <-r.Context().Done():
+				return
+			}
+			// Events for other operations are ignored.
+			fmt.Fprint(w, "event: next\ndata: {\"id\":\"other\",\"payload\":{\"data\":{\"n\":0}}}\n\n")
+			fmt.Fprintf(w, "event: next\ndata: {\"id\":%q,\"payload\":{\"data\":{\"n\":1}}}\n\n", id)
+			fmt.Fprintf(w, "event: next\ndata: {\"id\":%q,\"payload\":{\"data\":{\"n\":2}}}\n", id)
+			if r.URL.Query().Get("eof") != "" {
+				return
+			}
+			fmt.Fprint(w, "\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case "DELETE":
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+}
+
+func TestSubscriptionSingleSSE(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		params string
+		count  int
+		expect []string
+	}{
+		{
+			name:   "count",
+			count:  2,
+			expect: []string{"PUT  ", "GET tok-1 ", "POST tok-1 ", "DELETE tok-1 1"},
+		},
+		{
+			name:   "eof",
+			params: "?eof=1",
+			expect: []string{"PUT  ", "GET tok-1 ", "POST tok-1 "},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			requests := make(chan string, 10)
+			ts := sseSingleServer(requests)
+			defer ts.Close()
+
+			dir := writeFiles(t, map[string]string{
+				"sub.sen": fmt.Sprintf(`{steps: [{
+  label: sub
+  path: %q
+  content: "subscription {n}"
+  subscribe: {protocol: graphql-sse-single count: %d}
+  expect: [{data: {n: 1}} {data: {n: 2}}]
+}]}`, c.params, c.count),
+			})
+			r := Runner{
+				Server:   ts.URL,
+				Writer:   ioutil.Discard,
+				UseCases: loadUseCases(t, dir, "sub.sen"),
+			}
+			if err := r.Run(); err != nil {
+				t.Fatal(err)
+			}
+			ts.Close()
+			close(requests)
+			var got []string
+			for req := range requests {
+				got = append(got, req)
+			}
+			if strings.Join(got, "|") != strings.Join(c.expect, "|") {
+				t.Errorf("expected requests %q, got %q", c.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/ohler55/ojg/oj"
+	"github.com/ohler55/ojg/sen"
+)
+
+// Suite is an ordered group of use cases with shared setup and teardown
+// steps. A suite is read from a file with the "comment", "setup",
+// "useCases", "exclude", and "teardown" fields.
+type Suite struct {
+
+	// Comment is the description of the suite.
+	Comment string
+
+	// Filepath is the path to the file that the suite was read from.
+	Filepath string
+
+	// Setup steps are run once before the use cases. Values remembered by
+	// the setup steps are available to every use case. Setup is nil if the
+	// suite has no setup steps.
+	Setup *UseCase
+
+	// UseCases of the suite in the order they are to be run.
+	UseCases []*UseCase
+
+	// Teardown steps are always run after the use cases. Teardown is nil if
+	// the suite has no teardown steps.
+	Teardown *UseCase
+}
+
+// NewSuite creates a new Suite from a file. The "useCases" of the file are
+// files, directories, or glob patterns relative to the directory of the
+// suite file and are found with FindUseCases along with the "exclude"
+// patterns. The "setup" and "teardown" are steps in the same form as the
+// steps of a use case including includes.
+func NewSuite(path string) (s *Suite, err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(path); err != nil {
+		return
+	}
+	var p sen.Parser
+	var v interface{}
+	if v, err = p.Parse(data); err != nil {
+		return
+	}
+	m, _ := v.(map[string]interface{})
+	if m == nil {
+		return nil, fmt.Errorf("expected a map, not a %T", v)
+	}
+	s = &Suite{Filepath: path}
+	if s.Comment, err = asString(m["comment"]); err != nil {
+		return nil, err
+	}
+	src := locate(path, data)
+	if s.Setup, err = s.phase("setup", m["setup"], src.get("setup")); err != nil {
+		return nil, err
+	}
+	if s.Teardown, err = s.phase("teardown", m["teardown"], src.get("teardown")); err != nil {
+		return nil, err
+	}
+	var args []string
+	if args, err = asStrings(m["useCases"]); err != nil {
+		return nil, err
+	}
+	var exclude []string
+	if exclude, err = asStrings(m["exclude"]); err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+	for i, arg := range args {
+		if !filepath.IsAbs(arg) {
+			args[i] = filepath.Join(dir, arg)
+		}
+	}
+	var paths []string
+	if paths, err = FindUseCases(args, nil, exclude); err != nil {
+		return nil, err
+	}
+	self := filepath.Clean(path)
+	for _, ucPath := range paths {
+		// A directory or glob pattern may include the suite file itself.
+		if filepath.Clean(ucPath) == self {
+			continue
+		}
+		var uc *UseCase
+		if uc, err = NewUseCase(ucPath); err != nil {
+			return nil, err
+		}
+		s.UseCases = append(s.UseCases, uc)
+	}
+	return
+}
+
+// phase creates a use case for the setup or teardown steps.
+func (s *Suite) phase(name string, v interface{}, src *srcNode) (*UseCase, error) {
+	if v == nil {
+		return nil, nil
+	}
+	uc := &UseCase{Filepath: s.Filepath + " (" + name + ")"}
+	if name == "setup" {
+		uc.Comment = s.Comment
+	}
+	if err := uc.addSteps(v, src); err != nil {
+		return nil, err
+	}
+	return uc, nil
+}
+
+// AddTo sets the Setup and Teardown of the runner to those of the suite and
+// adds the suite use cases to the runner UseCases.
+func (s *Suite) AddTo(r *Runner) {
+	r.Setup = s.Setup
+	r.Teardown = s.Teardown
+	r.UseCases = append(r.UseCases, s.UseCases...)
+}
+
+// String representation of the suite.
+func (s *Suite) String() string {
+	return oj.JSON(s)
+}
+
+// Native returns a simplified version of the suite.
+func (s *Suite) Native() interface{} {
+	cases := make([]interface{}, 0, len(s.UseCases))
+	for _, uc := range s.UseCases {
+		cases = append(cases, uc.Filepath)
+	}
+	native := map[string]interface{}{
+		"useCases": cases,
+	}
+	if 0 < len(s.Comment) {
+		native["comment"] = easyString(s.Comment)
+	}
+	if s.Setup != nil {
+		native["setup"] = s.Setup.Native().(map[string]interface{})["steps"]
+	}
+	if s.Teardown != nil {
+		native["teardown"] = s.Teardown.Native().(map[string]interface{})["steps"]
+	}
+	return native
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RunTests runs the use case files that match the glob pattern as Go tests.
+// The pattern follows the same rules as the glob patterns of FindUseCases
+// so a "**" element matches zero or more directories and step include files
+// are skipped. Each use case file is run as a subtest named with the file
+// path relative to the leading directories of the pattern, such as
+// "songs.json" for the "gtt/*.json" pattern or "songs/a.json" for the
+// "gtt/**/*.json" pattern, so a single file can be selected with the -run
+// flag.
+// Each step is run as a nested subtest named with the step label or, if the
+// step has no label, the step number. The Server, Handler, Client, display,
+// and reporter settings of the runner are used and the reporter receives
+// the run start and end events. The Setup and Teardown of the runner, if
+// set, are run as the first and last subtests. The UseCases of the runner
+// are ignored. Failures and mismatches are reported with t.Errorf and the
+// output of each step is written with t.Log so it is displayed with the -v
+// flag or on failure.
+//
+//	func TestSongs(t *testing.T) {
+//	    gtt.RunTests(t, "gtt/*.json", &gtt.Runner{Handler: handler, Base: "/graphql"})
+//	}
+func RunTests(t *testing.T, pattern string, r *Runner) {
+	t.Helper()
+	runTests(t, nil, pattern, r)
+}
+
+// RunTestsFS is the same as RunTests except the use case files are read
+// from the fsys file system such as an embed.FS.
+//
+//	//go:embed testdata
+//	var suite embed.FS
+//
+//	func TestSongs(t *testing.T) {
+//	    gtt.RunTestsFS(t, suite, "testdata/*.json", &gtt.Runner{Handler: handler, Base: "/graphql"})
+//	}
+func RunTestsFS(t *testing.T, fsys fs.FS, pattern string, r *Runner) {
+	t.Helper()
+	runTests(t, fsys, pattern, r)
+}
+
+func runTests(t *testing.T, fsys fs.FS, pattern string, r *Runner) {
+	t.Helper()
+	paths, err := globFiles(fsys, pattern, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no use case files match %s", pattern)
+	}
+	sort.Strings(paths)
+	if r.Handler != nil && r.Client == nil && r.Transport == nil {
+		defer r.serveHandler()()
+	}
+	r.seed = nil
+	defer func() { r.seed = nil }()
+
+	start := time.Now()
+	r.emit(&Event{Kind: RunStart, Count: len(paths)})
+	defer func() {
+		ev := Event{Kind: RunEnd, Status: Pass, Duration: time.Since(start)}
+		switch {
+		case err != nil:
+			ev.Status = Fail
+			ev.Error = err.Error()
+		case t.Failed():
+			ev.Status = Fail
+		}
+		r.emit(&ev)
+	}()
+	if r.Teardown != nil {
+		defer t.Run(r.Teardown.Filepath, func(t *testing.T) { r.Teardown.test(t, r) })
+	}
+	if r.Setup != nil {
+		var ucr *UseCaseResult
+		t.Run(r.Setup.Filepath, func(t *testing.T) { ucr = r.Setup.test(t, r) })
+		if ucr == nil || ucr.err != nil {
+			err = fmt.Errorf("use cases not run due to a setup failure")
+			t.Error(err)
+			return
+		}
+		r.seed = ucr.Memory
+	}
+	root := globRoot(pattern)
+	for _, path := range paths {
+		path := path
+		t.Run(testName(root, path), func(t *testing.T) {
+			uc, lerr := newUseCase(fsys, path)
+			if lerr != nil {
+				if err == nil {
+					err = lerr
+				}
+				t.Fatalf("failed to load use case. %s", lerr)
+			}
+			if ucr := uc.test(t, r); ucr.err != nil && err == nil {
+				err = ucr.err
+			}
+		})
+	}
+}
+
+// globRoot returns the leading directories of a glob pattern that do not
+// include any pattern characters.
+func globRoot(pattern string) string {
+	elements := strings.Split(filepath.ToSlash(pattern), "/")
+	var i int
+	for i < len(elements)-1 && !hasMeta(elements[i]) {
+		i++
+	}
+	return strings.Join(elements[:i], "/")
+}
+
+// testName returns the path of a use case file relative to the root of the
+// glob pattern so that subtest names can be matched with the -run flag.
+func testName(root, path string) string {
+	path = filepath.ToSlash(path)
+	if 0 < len(root) {
+		path = strings.TrimPrefix(path, root+"/")
+	}
+	return path
+}
+
+// test runs the use case with a subtest for each step.
+func (uc *UseCase) test(t *testing.T, r *Runner) *UseCaseResult {
+	uc.out = &strings.Builder{}
+	defer func() { uc.out = nil }()
+
+	ucr := uc.begin(r)
+	uc.flush(t)
+	for i, step := range uc.Steps {
+		name := step.Label
+		if len(name) == 0 {
+			name = fmt.Sprintf("step-%d", i+1)
+		}
+		if ucr.err != nil && !step.Always {
+			uc.step(step, ucr)
+			t.Run(name, func(t *testing.T) { t.Skip("skipped after an earlier failure") })
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			sr := uc.step(step, ucr)
+			uc.flush(t)
+			if sr.err == nil {
+				return
+			}
+			if len(sr.Mismatches) == 0 {
+				t.Error(sr.err)
+				return
+			}
+			for _, m := range sr.Mismatches {
+				if m.Position != nil {
+					t.Errorf("%s: mismatch at %s. expected %v, actual %v", m.Position, m.Path, m.Expect, m.Actual)
+				} else {
+					t.Errorf("mismatch at %s. expected %v, actual %v", m.Path, m.Expect, m.Actual)
+				}
+			}
+		})
+	}
+	uc.end(ucr)
+	uc.flush(t)
+
+	return ucr
+}
+
+// flush the buffered output of the use case to the test log.
+func (uc *UseCase) flush(t *testing.T) {
+	t.Helper()
+	if out := strings.TrimSpace(uc.out.String()); 0 < len(out) {
+		t.Log("\n" + out)
+	}
+	uc.out.Reset()
+}
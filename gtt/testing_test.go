@@ -0,0 +1,117 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRunTests(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"a.sen": `{steps: [{label: one content: "{a}" expect: {data: {a: 1}}}]}`,
+		"b.sen": `{steps: [{label: one content: "{a}"} {label: two content: "{a}"}]}`,
+	})
+	var tap bytes.Buffer
+	RunTests(t, filepath.Join(dir, "*.sen"), &Runner{
+		Server:    ts.URL,
+		Reporters: []Reporter{&TAPReporter{Writer: &tap}},
+	})
+	out := tap.String()
+	if !strings.HasPrefix(out, "TAP version 13\n") {
+		t.Errorf("expected a TAP header, got %s", out)
+	}
+	if !strings.HasSuffix(out, "1..3\n") {
+		t.Errorf("expected a TAP plan of 3, got %s", out)
+	}
+}
+
+func TestRunTestsRecursive(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"a":1}}`))
+	}))
+	defer ts.Close()
+
+	files := map[string]string{
+		"suite/a.sen":          `{steps: [{label: one content: "{a}"}]}`,
+		"suite/sub/b.sen":      `{steps: [{label: one content: "{a}"}]}`,
+		"suite/sub/deep/c.sen": `{steps: [{label: one include: "../../inc/steps.sen"}]}`,
+		"suite/inc/steps.sen":  `[{label: inc content: "{a}"}]`,
+		"suite/sub/d.json":     `{steps: [{label: one content: "{a}"}]}`,
+		"other/e.sen":          `{steps: [{label: one content: "{a}"}]}`,
+	}
+	expect := []string{
+		"ok 1 - suite/a.sen: one",
+		"ok 2 - suite/sub/b.sen: one",
+		"ok 3 - suite/sub/deep/c.sen: one",
+		"1..3",
+	}
+	dir := writeFiles(t, files)
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	for _, c := range []struct {
+		name string
+		run  func(t *testing.T, r *Runner)
+		root string
+	}{
+		{
+			name: "dir",
+			run:  func(t *testing.T, r *Runner) { RunTests(t, filepath.Join(dir, "suite/**/*.sen"), r) },
+			root: filepath.ToSlash(dir) + "/",
+		},
+		{
+			name: "fs",
+			run:  func(t *testing.T, r *Runner) { RunTestsFS(t, fsys, "suite/**/*.sen", r) },
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var tap bytes.Buffer
+			c.run(t, &Runner{
+				Server:    ts.URL,
+				Reporters: []Reporter{&TAPReporter{Writer: &tap}},
+			})
+			var points []string
+			for _, line := range strings.Split(tap.String(), "\n") {
+				if strings.HasPrefix(line, "ok ") || strings.HasPrefix(line, "not ok ") || strings.HasPrefix(line, "1..") {
+					points = append(points, strings.Replace(filepath.ToSlash(line), c.root, "", 1))
+				}
+			}
+			if strings.Join(points, "\n") != strings.Join(expect, "\n") {
+				t.Errorf("expected\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(points, "\n"))
+			}
+		})
+	}
+}
+
+func TestTestName(t *testing.T) {
+	for _, c := range []struct {
+		pattern string
+		path    string
+		expect  string
+	}{
+		{pattern: "gtt/*.json", path: "gtt/a.json", expect: "a.json"},
+		{pattern: "*.json", path: "a.json", expect: "a.json"},
+		{pattern: "/tmp/x/gtt/*.json", path: "/tmp/x/gtt/a.json", expect: "a.json"},
+		{pattern: "gtt/*/*.json", path: "gtt/songs/a.json", expect: "songs/a.json"},
+		{pattern: "gtt/a.json", path: "gtt/a.json", expect: "a.json"},
+		{pattern: "g?t/a.json", path: "gtt/a.json", expect: "gtt/a.json"},
+		{pattern: "gtt/**/*.json", path: "gtt/a.json", expect: "a.json"},
+		{pattern: "gtt/**/*.json", path: "gtt/songs/pop/a.json", expect: "songs/pop/a.json"},
+	} {
+		if name := testName(globRoot(c.pattern), c.path); name != c.expect {
+			t.Errorf("%s %s: expected %s, got %s", c.pattern, c.path, c.expect, name)
+		}
+	}
+}
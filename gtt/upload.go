@@ -0,0 +1,212 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+// Upload is a file to be uploaded with a GraphQL multipart request. The
+// content of the file is either read from the file at Path or is the Content
+// provided.
+type Upload struct {
+
+	// Path to the file to upload. A relative path is relative to the
+	// directory of the use case file. Paths are always relative if the use
+	// case was loaded from an fs.FS.
+	Path string
+
+	// Content of the file if Path is empty.
+	Content string
+
+	// Name is the filename sent with the file. It defaults to the base of
+	// the Path or "upload" if the Content is used.
+	Name string
+
+	// Type is the content type of the file. If not set it is determined
+	// from the extension of the Name and defaults to
+	// "application/octet-stream".
+	Type string
+}
+
+// Set the members of the upload based on the data provided. A string is the
+// path to a file while a map can have path, content, name, and type members.
+func (up *Upload) Set(data interface{}) (err error) {
+	switch td := data.(type) {
+	case string:
+		up.Path = td
+		return nil
+	case map[string]interface{}:
+		up.Path, _ = td["path"].(string)
+		up.Name, _ = td["name"].(string)
+		up.Type, _ = td["type"].(string)
+		if up.Content, err = asString(td["content"]); err != nil {
+			return
+		}
+		if len(up.Path) == 0 && len(up.Content) == 0 {
+			return fmt.Errorf("an upload must have a path or content")
+		}
+		return nil
+	}
+	return fmt.Errorf("%T is not a valid type for an upload", data)
+}
+
+// Native representation of the upload.
+func (up *Upload) Native() interface{} {
+	if len(up.Content) == 0 && len(up.Name) == 0 && len(up.Type) == 0 {
+		return up.Path
+	}
+	native := map[string]interface{}{}
+	if 0 < len(up.Path) {
+		native["path"] = up.Path
+	}
+	if 0 < len(up.Content) {
+		native["content"] = easyString(up.Content)
+	}
+	if 0 < len(up.Name) {
+		native["name"] = up.Name
+	}
+	if 0 < len(up.Type) {
+		native["type"] = up.Type
+	}
+	return native
+}
+
+func (up *Upload) read(uc *UseCase) (name string, data []byte, err error) {
+	name = up.Name
+	if len(up.Path) == 0 {
+		if len(name) == 0 {
+			name = "upload"
+		}
+		return name, []byte(up.Content), nil
+	}
+	path := up.Path
+	if uc.fsys != nil || !filepath.IsAbs(path) {
+		path = uc.relPath(path)
+	}
+	if data, err = uc.readFile(path); err != nil {
+		return
+	}
+	if len(name) == 0 {
+		name = filepath.Base(path)
+	}
+	return
+}
+
+// uploadContent builds a GraphQL multipart request body with the operations,
+// map, and file parts. The content type, body, and a description suitable for
+// logging are returned.
+func (s *Step) uploadContent(uc *UseCase, vars map[string]interface{}) (contentType string, body []byte, desc string, err error) {
+	paths := make([]string, 0, len(s.Files))
+	for path := range s.Files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	operations := map[string]interface{}{}
+	if err = uc.setDocument(operations, s.Content); err != nil {
+		return
+	}
+	if 0 < len(s.Op) {
+		operations["operationName"] = s.Op
+	}
+	fileMap := map[string]interface{}{}
+	for i, path := range paths {
+		if !strings.HasPrefix(path, "variables.") {
+			path = "variables." + path
+		}
+		vars, _ = setNullAt(vars, strings.Split(path, ".")[1:]).(map[string]interface{})
+		fileMap[strconv.Itoa(i)] = []interface{}{path}
+	}
+	operations["variables"] = vars
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	ops := oj.JSON(operations)
+	if err = w.WriteField("operations", ops); err != nil {
+		return
+	}
+	mapStr := oj.JSON(fileMap, &oj.Options{Sort: true})
+	if err = w.WriteField("map", mapStr); err != nil {
+		return
+	}
+	descs := []string{"operations: " + ops, "map: " + mapStr}
+	for i, path := range paths {
+		up := s.Files[path]
+		var name string
+		var data []byte
+		if name, data, err = up.read(uc); err != nil {
+			return
+		}
+		ct := up.Type
+		if len(ct) == 0 {
+			if ct = mime.TypeByExtension(filepath.Ext(name)); len(ct) == 0 {
+				ct = "application/octet-stream"
+			}
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%d"; filename="%s"`, i, quoteEscaper.Replace(name)))
+		h.Set("Content-Type", ct)
+		var pw io.Writer
+		if pw, err = w.CreatePart(h); err != nil {
+			return
+		}
+		if _, err = pw.Write(data); err != nil {
+			return
+		}
+		descs = append(descs, fmt.Sprintf("%d: %s (%s, %d bytes)", i, name, ct, len(data)))
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+	return w.FormDataContentType(), buf.Bytes(), strings.Join(descs, "\n"), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// setNullAt returns a copy of the value with the value at the path set to
+// nil. Maps and lists are created along the path as needed.
+func setNullAt(v interface{}, path []string) interface{} {
+	if len(path) == 0 {
+		return nil
+	}
+	key := path[0]
+	n, err := strconv.Atoi(key)
+	switch tv := v.(type) {
+	case []interface{}:
+		if err != nil || n < 0 {
+			return v
+		}
+		list := make([]interface{}, len(tv))
+		copy(list, tv)
+		for len(list) <= n {
+			list = append(list, nil)
+		}
+		list[n] = setNullAt(list[n], path[1:])
+		return list
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(tv)+1)
+		for k, mv := range tv {
+			m[k] = mv
+		}
+		m[key] = setNullAt(m[key], path[1:])
+		return m
+	case nil:
+		if err == nil && 0 <= n {
+			return setNullAt([]interface{}{}, path)
+		}
+		return setNullAt(map[string]interface{}{}, path)
+	}
+	return v
+}
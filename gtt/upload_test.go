@@ -0,0 +1,198 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ohler55/ojg/oj"
+	"github.com/ohler55/ojg/sen"
+)
+
+func uploadServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var ops interface{}
+		var fileMap interface{}
+		_ = json.Unmarshal([]byte(r.FormValue("operations")), &ops)
+		_ = json.Unmarshal([]byte(r.FormValue("map")), &fileMap)
+		files := map[string]interface{}{}
+		for k, fhs := range r.MultipartForm.File {
+			f, _ := fhs[0].Open()
+			data, _ := ioutil.ReadAll(f)
+			_ = f.Close()
+			files[k] = map[string]interface{}{
+				"name":    fhs[0].Filename,
+				"type":    fhs[0].Header.Get("Content-Type"),
+				"content": string(data),
+			}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"operations": ops, "map": fileMap, "files": files},
+		})
+	}))
+}
+
+func TestUploadDocument(t *testing.T) {
+	ts := uploadServer()
+	defer ts.Close()
+
+	const query = "mutation($file: Upload!) { upload(file: $file) }"
+	dir := writeFiles(t, map[string]string{
+		"a.txt": "hello",
+		"query.sen": `{steps: [{
+  label: upload
+  content: "` + query + `"
+  files: {file: "a.txt"}
+  expect: {data: {
+    operations: {query: "` + query + `" variables: {file: null}}
+    files: {"0": {name: "a.txt" content: hello}}
+  }}
+}]}`,
+		"document.sen": `{steps: [{
+  label: upload
+  content: "` + query + `"
+  files: {file: "a.txt"}
+  expect: {data: {operations: {documentId: "` + queryHash(NormalizeQuery(query)) + `" variables: {file: null}}}}
+}]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "query.sen"),
+	}
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+	r.UseCases = loadUseCases(t, dir, "document.sen")
+	r.Manifest = NewManifest(r.UseCases)
+	rep, err := r.RunReport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := rep.UseCases[0].Steps[0].Response
+	var resp map[string]map[string]map[string]interface{}
+	if err = json.Unmarshal([]byte(ops), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if q, has := resp["data"]["operations"]["query"]; has {
+		t.Errorf("expected no query with a manifest, got %v", q)
+	}
+}
+
+func TestUploadFiles(t *testing.T) {
+	ts := uploadServer()
+	defer ts.Close()
+
+	const query = "mutation($files: [Upload!]! $input: Input!) { upload(files: $files input: $input) }"
+	dir := writeFiles(t, map[string]string{
+		"sub/b.json": `{"b":true}`,
+		"upload.sen": `{steps: [{
+  label: upload
+  content: "` + query + `"
+  vars: {files: [keep x] input: {title: t}}
+  files: {
+    "files.1": {content: inline}
+    "variables.input.doc": "sub/b.json"
+    "extra.0": {content: "a,b" name: "c.csv" type: "text/x-custom"}
+  }
+  expect: {data: {
+    operations: {
+      query: "` + query + `"
+      variables: {files: [keep null] input: {title: t doc: null} extra: [null]}
+    }
+    map: {"0": ["variables.extra.0"] "1": ["variables.files.1"] "2": ["variables.input.doc"]}
+    files: {
+      "0": {name: "c.csv" type: "text/x-custom" content: "a,b"}
+      "1": {name: upload type: "application/octet-stream" content: inline}
+      "2": {name: "b.json" type: "application/json" content: "{\"b\":true}"}
+    }
+  }}
+}]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "upload.sen"),
+	}
+	if err := r.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUploadMissingFile(t *testing.T) {
+	ts := uploadServer()
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"upload.sen": `{steps: [{label: upload content: "mutation {upload}" files: {file: "missing.txt"}}]}`,
+	})
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: loadUseCases(t, dir, "upload.sen"),
+	}
+	if err := r.Run(); err == nil {
+		t.Error("expected an error for a missing upload file")
+	}
+}
+
+func TestUploadSet(t *testing.T) {
+	for _, c := range []struct {
+		data   string
+		expect string
+		err    bool
+	}{
+		{data: `"a.txt"`, expect: `"a.txt"`},
+		{data: `{content: abc}`, expect: `{"content":"abc"}`},
+		{data: `{path: "a.txt" name: "b.txt" type: "text/plain"}`, expect: `{"name":"b.txt","path":"a.txt","type":"text/plain"}`},
+		{data: `{name: "b.txt"}`, err: true},
+		{data: `3`, err: true},
+	} {
+		var up Upload
+		err := up.Set(sen.MustParse([]byte(c.data)))
+		switch {
+		case c.err && err == nil:
+			t.Errorf("%s: expected an error", c.data)
+		case !c.err && err != nil:
+			t.Errorf("%s: unexpected error. %s", c.data, err)
+		case !c.err:
+			if native := oj.JSON(up.Native(), &oj.Options{Sort: true}); native != c.expect {
+				t.Errorf("%s: expected %s, got %s", c.data, c.expect, native)
+			}
+		}
+	}
+}
+
+func TestSetNullAt(t *testing.T) {
+	for _, c := range []struct {
+		value  string
+		path   []string
+		expect string
+	}{
+		{value: `{}`, path: []string{"file"}, expect: `{"file":null}`},
+		{value: `{"file":"x"}`, path: []string{"file"}, expect: `{"file":null}`},
+		{value: `{}`, path: []string{"files", "1"}, expect: `{"files":[null,null]}`},
+		{value: `{"files":["a","b","c"]}`, path: []string{"files", "1"}, expect: `{"files":["a",null,"c"]}`},
+		{value: `{"in":{"a":1}}`, path: []string{"in", "doc"}, expect: `{"in":{"a":1,"doc":null}}`},
+		{value: `{"in":[{"a":1}]}`, path: []string{"in", "0", "doc"}, expect: `{"in":[{"a":1,"doc":null}]}`},
+		{value: `{"files":["a"]}`, path: []string{"files", "x"}, expect: `{"files":["a"]}`},
+		{value: `{"s":"a"}`, path: []string{"s", "x"}, expect: `{"s":"a"}`},
+	} {
+		v := oj.MustParseString(c.value)
+		before := oj.JSON(v, &oj.Options{Sort: true})
+		if got := oj.JSON(setNullAt(v, c.path), &oj.Options{Sort: true}); got != c.expect {
+			t.Errorf("%s %v: expected %s, got %s", c.value, c.path, c.expect, got)
+		}
+		if after := oj.JSON(v, &oj.Options{Sort: true}); after != before {
+			t.Errorf("%s %v: the original value was modified to %s", c.value, c.path, after)
+		}
+	}
+}
@@ -3,10 +3,15 @@
 package gtt
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"net/http"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ohler55/ojg/oj"
 	"github.com/ohler55/ojg/sen"
@@ -26,50 +31,83 @@ type UseCase struct {
 	// Steps are the steps to be taken in the use case.
 	Steps []*Step
 
-	runner *Runner
-	memory map[string]interface{}
+	runner  *Runner
+	memory  map[string]interface{}
+	out     *strings.Builder
+	streams map[string]*stream
+	jar     http.CookieJar
+	fsys    fs.FS
 }
 
 // NewUseCase creates a new UseCase from a file.
 func NewUseCase(filepath string) (uc *UseCase, err error) {
-	var data []byte
+	return newUseCase(nil, filepath)
+}
+
+// NewUseCaseFS creates a new UseCase from a file in the fsys file system such
+// as an embed.FS. Includes and uploads are read from the same file system
+// relative to the use case file.
+func NewUseCaseFS(fsys fs.FS, name string) (uc *UseCase, err error) {
+	return newUseCase(fsys, name)
+}
 
-	if data, err = ioutil.ReadFile(filepath); err != nil {
-		return
+func newUseCase(fsys fs.FS, filepath string) (uc *UseCase, err error) {
+	uc = &UseCase{Filepath: filepath, fsys: fsys}
+
+	var data []byte
+	if data, err = uc.readFile(filepath); err != nil {
+		return nil, err
 	}
 	var m map[string]interface{}
 	var p sen.Parser
 	var v interface{}
 	if v, err = p.Parse(data); err != nil {
-		return
+		return nil, err
 	}
 	if m, _ = v.(map[string]interface{}); m == nil {
 		return nil, fmt.Errorf("expected a map, not a %T", v)
 	}
-	uc = &UseCase{Filepath: filepath}
 	if uc.Comment, err = asString(m["comment"]); err != nil {
-		return
+		return nil, err
 	}
-	if err = uc.addSteps(m["steps"]); err != nil {
-		return
+	if err = uc.addSteps(m["steps"], locate(filepath, data).get("steps")); err != nil {
+		return nil, err
 	}
 	return
 }
 
+// readFile reads a file from the file system of the use case or from the OS
+// file system if the use case was not loaded from an fs.FS.
+func (uc *UseCase) readFile(name string) ([]byte, error) {
+	if uc.fsys == nil {
+		return ioutil.ReadFile(name)
+	}
+	return fs.ReadFile(uc.fsys, name)
+}
+
+// relPath returns the path joined to the directory of the use case file.
+func (uc *UseCase) relPath(rel string) string {
+	if uc.fsys != nil {
+		return path.Join(path.Dir(uc.Filepath), rel)
+	}
+	return filepath.Join(filepath.Dir(uc.Filepath), rel)
+}
+
 // The arg can be either a string, array, or a map. A map is assumed to be a
 // single step while a string is a relative path to a file to include. The
-// included file should be an array of steps or steps and additional includes.
-func (uc *UseCase) addSteps(v interface{}) error {
+// included file should be an array of steps or steps and additional
+// includes. The src node, if not nil, holds the source positions of the arg.
+func (uc *UseCase) addSteps(v interface{}, src *srcNode) error {
 	switch tv := v.(type) {
 	case []interface{}:
-		for _, v = range tv {
-			if err := uc.addSteps(v); err != nil {
+		for i, v := range tv {
+			if err := uc.addSteps(v, src.get(i)); err != nil {
 				return err
 			}
 		}
 	case string:
-		filepath := filepath.Join(filepath.Dir(uc.Filepath), tv)
-		data, err := ioutil.ReadFile(filepath)
+		filepath := uc.relPath(tv)
+		data, err := uc.readFile(filepath)
 		if err != nil {
 			return err
 		}
@@ -82,12 +120,20 @@ func (uc *UseCase) addSteps(v interface{}) error {
 		if steps, _ = pd.([]interface{}); steps == nil {
 			return fmt.Errorf("expected a array, not a %T", pd)
 		}
-		return uc.addSteps(steps)
+		return uc.addSteps(steps, locate(filepath, data))
 	case map[string]interface{}:
 		step := Step{}
 		if err := step.Set(v); err != nil {
 			return err
 		}
+		if src != nil {
+			step.Position = &src.pos
+			step.expectSrc = src.get("expect")
+			step.patchesSrc = src.get("patches")
+			step.apqSrc = src.get("apq").get("expect")
+			step.headersSrc = src.get("expectHeaders")
+			step.cookiesSrc = src.get("expectCookies")
+		}
 		uc.Steps = append(uc.Steps, &step)
 	default:
 		return fmt.Errorf("%T is not a valid steps type", v)
@@ -131,9 +177,36 @@ func (uc *UseCase) Simplify() interface{} {
 
 // Run the use case.
 func (uc *UseCase) Run(r *Runner) (err error) {
+	return uc.run(r).err
+}
+
+func (uc *UseCase) run(r *Runner) *UseCaseResult {
+	ucr := uc.begin(r)
+	for _, step := range uc.Steps {
+		uc.step(step, ucr)
+	}
+	uc.end(ucr)
+
+	return ucr
+}
+
+// begin a run of the use case.
+func (uc *UseCase) begin(r *Runner) *UseCaseResult {
+	ucr := &UseCaseResult{Filepath: uc.Filepath, Status: Pass, start: time.Now()}
 	uc.runner = r
-	// Start with a fresh memory cache as each run is separate from any other.
-	uc.memory = map[string]interface{}{}
+	// Start with a fresh memory cache as each run is separate from any other
+	// except for the values remembered by the runner Setup.
+	uc.memory = copyMemory(r.seed)
+	uc.streams = map[string]*stream{}
+	// The jar of the runner client takes precedence over ShareCookies.
+	switch {
+	case r.Client != nil && r.Client.Jar != nil:
+		uc.jar = r.Client.Jar
+	case r.ShareCookies:
+		uc.jar = r.cookieJar()
+	default:
+		uc.jar = newJar()
+	}
 	path := uc.Filepath
 	if !r.NoColor {
 		if 80 <= len(path) {
@@ -143,18 +216,69 @@ func (uc *UseCase) Run(r *Runner) (err error) {
 		}
 	}
 	if 0 < len(uc.Comment) {
-		r.Log(aComment, "\n%s\n%s\n", path, uc.Comment)
+		uc.log(aComment, "\n%s\n%s\n", path, uc.Comment)
 	} else {
-		r.Log(aComment, "\n%s\n", path)
+		uc.log(aComment, "\n%s\n", path)
 	}
-	for _, step := range uc.Steps {
-		if err == nil {
-			err = step.Execute(uc)
-		} else if step.Always {
-			_ = step.Execute(uc)
+	uc.emit(nil, &Event{Kind: UseCaseStart})
+
+	return ucr
+}
+
+// step executes a step of the use case unless an earlier step has failed
+// and the step is not marked as always.
+func (uc *UseCase) step(step *Step, ucr *UseCaseResult) *StepResult {
+	sr := &StepResult{Label: step.Label, Status: Skip}
+	ucr.Steps = append(ucr.Steps, sr)
+	if ucr.err != nil && !step.Always {
+		uc.emit(step, &Event{Kind: StepEnd, Status: Skip})
+		return sr
+	}
+	uc.emit(step, &Event{Kind: StepStart})
+	stepStart := time.Now()
+	err := step.execute(uc, sr)
+	if err != nil && step.Position != nil {
+		err = fmt.Errorf("%s: %w", step.Position, err)
+	}
+	sr.Duration = time.Since(stepStart)
+	sr.Memory = copyMemory(uc.memory)
+	if err != nil {
+		sr.Status = Fail
+		sr.Error = err.Error()
+		sr.err = err
+		if ucr.err == nil {
+			ucr.err = err
+			ucr.Status = Fail
+			ucr.Error = err.Error()
 		}
+	} else {
+		sr.Status = Pass
 	}
-	return
+	uc.emit(step, &Event{Kind: StepEnd, Status: sr.Status, Duration: sr.Duration, Error: sr.Error})
+
+	return sr
+}
+
+// end a run of the use case.
+func (uc *UseCase) end(ucr *UseCaseResult) {
+	// Stop any background subscriptions that were not awaited.
+	for _, st := range uc.streams {
+		st.stop()
+	}
+	uc.streams = nil
+	ucr.Memory = copyMemory(uc.memory)
+	ucr.Duration = time.Since(ucr.start)
+	uc.emit(nil, &Event{Kind: UseCaseEnd, Status: ucr.Status, Duration: ucr.Duration, Error: ucr.Error})
+}
+
+// emit an event for the use case and optionally a step to the runner
+// reporters.
+func (uc *UseCase) emit(step *Step, ev *Event) {
+	ev.Filepath = uc.Filepath
+	if step != nil {
+		ev.Step = step.Label
+	}
+	uc.runner.emit(ev)
 }
 
 // Memory is a map of the variables remembered.
@@ -162,6 +286,72 @@ func (uc *UseCase) Memory() map[string]interface{} {
 	return uc.memory
 }
 
+// log to the runner unless output is being buffered for the use case in which
+// case the output is written to the buffer.
+func (uc *UseCase) log(color string, format string, args ...interface{}) {
+	if str, ok := uc.runner.format(color, format, args...); ok {
+		if uc.out != nil {
+			uc.out.WriteString(str)
+		} else {
+			uc.runner.write(str)
+		}
+	}
+}
+
+// client returns the HTTP client for the use case with the use case cookie
+// jar which is the jar of the runner client if it has one.
+func (uc *UseCase) client() *http.Client {
+	var c http.Client
+	if uc.runner != nil {
+		if uc.runner.Client != nil {
+			c = *uc.runner.Client
+		} else if t := uc.runner.transport(); t != nil {
+			c.Transport = t
+		}
+	}
+	c.Jar = uc.jar
+
+	return &c
+}
+
+// dialer returns the dial function and TLS configuration of the HTTP
+// transport if the transport is an *http.Transport.
+func (uc *UseCase) dialer() (dialFunc, *tls.Config) {
+	if t, ok := uc.client().Transport.(*http.Transport); ok {
+		return t.DialContext, t.TLSClientConfig
+	}
+	return nil, nil
+}
+
+// resolveVars returns a copy of the variables with any string value that
+// begins with a '$' replaced by the remembered value.
+func (uc *UseCase) resolveVars(vars map[string]interface{}) map[string]interface{} {
+	resolved := map[string]interface{}{}
+	for k, v := range vars {
+		if s, _ := v.(string); 0 < len(s) && s[0] == '$' {
+			v = uc.memory[s[1:]]
+		}
+		resolved[k] = v
+	}
+	return resolved
+}
+
+// setDocument sets the query of a request or, if the runner has a persisted
+// operation manifest, the documentId.
+func (uc *UseCase) setDocument(req map[string]interface{}, content string) error {
+	if uc.runner == nil || uc.runner.Manifest == nil {
+		req["query"] = content
+		return nil
+	}
+	id, ok := uc.runner.Manifest.ID(content)
+	if !ok {
+		return fmt.Errorf("operation is not in the manifest. %s", NormalizeQuery(content))
+	}
+	req["documentId"] = id
+
+	return nil
+}
+
 func (uc *UseCase) replaceVars(s string) string {
 	for k, v := range uc.memory {
 		pat := fmt.Sprintf("$(%s)", k)
@@ -0,0 +1,64 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewUseCaseFS(t *testing.T) {
+	ts := uploadServer()
+	defer ts.Close()
+
+	fsys := fstest.MapFS{
+		"suite/upload.sen": &fstest.MapFile{Data: []byte(`{steps: [
+  "inc/steps.sen"
+  {
+    label: upload
+    content: "mutation($file: Upload!) { upload(file: $file) }"
+    files: {file: "data/a.txt" other: "/data/a.txt"}
+    expect: {data: {files: {
+      "0": {name: "a.txt" content: hello}
+      "1": {name: "a.txt" content: hello}
+    }}}
+  }
+]}`)},
+		"suite/inc/steps.sen": &fstest.MapFile{Data: []byte(`[
+  {label: first content: "mutation { upload }"}
+]`)},
+		"suite/data/a.txt": &fstest.MapFile{Data: []byte("hello")},
+		"bad/include.sen":  &fstest.MapFile{Data: []byte(`{steps: ["missing.sen"]}`)},
+	}
+	uc, err := NewUseCaseFS(fsys, "suite/upload.sen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uc.Steps) != 2 || uc.Steps[0].Label != "first" || uc.Steps[1].Label != "upload" {
+		t.Fatalf("expected the included step and the upload step, got %s", uc.JSON())
+	}
+	for i, expect := range []string{"suite/inc/steps.sen:2:3", "suite/upload.sen:3:3"} {
+		if pos := uc.Steps[i].Position; pos == nil || pos.String() != expect {
+			t.Errorf("expected step %d at %s, got %v", i+1, expect, pos)
+		}
+	}
+	r := Runner{
+		Server:   ts.URL,
+		Writer:   ioutil.Discard,
+		UseCases: []*UseCase{uc},
+	}
+	if err = r.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewUseCaseFS(fsys, "bad/include.sen"); err == nil {
+		t.Error("expected an error for a missing include")
+	}
+	if _, err = NewUseCaseFS(fsys, "suite/missing.sen"); err == nil {
+		t.Error("expected an error for a missing use case")
+	}
+	if _, err = NewUseCaseFS(fsys, "suite/inc/steps.sen"); err == nil {
+		t.Error("expected an error for a step include file")
+	}
+}
@@ -5,8 +5,11 @@ package gtt
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/ohler55/ojg/jp"
 )
 
 // extracting from json/native
@@ -58,6 +61,26 @@ func asMapStrStr(value interface{}) (map[string]string, error) {
 	return nil, fmt.Errorf("%T is not a valid type for a map[string]string", value)
 }
 
+func asStrings(value interface{}) ([]string, error) {
+	switch tv := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{tv}, nil
+	case []interface{}:
+		sa := make([]string, 0, len(tv))
+		for _, v := range tv {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a string, not a %T", v)
+			}
+			sa = append(sa, s)
+		}
+		return sa, nil
+	}
+	return nil, fmt.Errorf("%T is not a valid type for a []string", value)
+}
+
 // json building helpers
 
 // if multiple lines then convert to an array
@@ -86,118 +109,149 @@ func addAny(m map[string]interface{}, key string, value interface{}) {
 
 // compare results
 
-// Returns path, result, expected
-func match(result interface{}, expect interface{}) ([]string, interface{}, interface{}) {
+// Returns all the differences between the result and the expected value.
+func match(result interface{}, expect interface{}) (mismatches []*Mismatch) {
+	matchAt(nil, result, expect, &mismatches)
+	return
+}
+
+func matchAt(loc []interface{}, result interface{}, expect interface{}, mismatches *[]*Mismatch) {
 	switch x := expect.(type) {
 	case map[string]interface{}:
 		if rm, ok := result.(map[string]interface{}); ok {
-			checked := map[string]bool{}
+			keys := make([]string, 0, len(x))
 			exact := false
-			for k, v := range x {
+			for k := range x {
 				if k == "*" {
 					exact = true
 				} else {
-					checked[k] = true
-				}
-				if loc, av, xv := match(rm[k], v); loc != nil {
-					return append([]string{k}, loc...), av, xv
+					keys = append(keys, k)
 				}
 			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				matchAt(appendLoc(loc, k), rm[k], x[k], mismatches)
+			}
 			if exact {
+				keys = keys[:0]
 				for k, v := range rm {
-					if !checked[k] && v != nil {
-						return []string{k}, v, nil
+					if _, has := x[k]; !has && v != nil {
+						keys = append(keys, k)
 					}
 				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					*mismatches = append(*mismatches, newMismatch(appendLoc(loc, k), rm[k], nil))
+				}
 			}
-		} else {
-			return []string{}, result, expect
+			return
 		}
 	case []interface{}:
 		if ra, ok := result.([]interface{}); ok {
 			for i, v := range x {
 				if len(ra) <= i {
-					return []string{}, nil, v
-				}
-				if loc, av, xv := match(ra[i], v); loc != nil {
-					return append([]string{strconv.Itoa(i)}, loc...), av, xv
+					*mismatches = append(*mismatches, newMismatch(appendLoc(loc, i), nil, v))
+					continue
 				}
+				matchAt(appendLoc(loc, i), ra[i], v, mismatches)
 			}
-			if len(ra) > len(x) {
-				return []string{}, ra, x
+			for i := len(x); i < len(ra); i++ {
+				*mismatches = append(*mismatches, newMismatch(appendLoc(loc, i), ra[i], nil))
 			}
-		} else {
-			return []string{}, result, expect
+			return
+		}
+	default:
+		if matchValue(result, expect) {
+			return
 		}
+	}
+	*mismatches = append(*mismatches, newMismatch(loc, result, expect))
+}
+
+func appendLoc(loc []interface{}, key interface{}) []interface{} {
+	return append(loc[:len(loc):len(loc)], key)
+}
+
+func newMismatch(loc []interface{}, actual interface{}, expect interface{}) *Mismatch {
+	path := make([]string, len(loc))
+	x := jp.R()
+	for i, key := range loc {
+		switch tk := key.(type) {
+		case string:
+			path[i] = tk
+			x = x.C(tk)
+		case int:
+			path[i] = strconv.Itoa(tk)
+			x = x.N(tk)
+		}
+	}
+	return &Mismatch{
+		Path:     strings.Join(path, "."),
+		JSONPath: x.String(),
+		Expect:   expect,
+		Actual:   actual,
+		loc:      loc,
+	}
+}
+
+// Returns true if a non-container result matches the expected value.
+func matchValue(result interface{}, expect interface{}) bool {
+	switch x := expect.(type) {
 	case string:
-		match := false
 		if 2 < len(x) && x[0] == '/' && x[len(x)-1] == '/' {
+			var match bool
 			if rs, ok := result.(string); ok {
-				match, _ = regexp.MatchString(x[1:len(x)-2], rs)
+				match, _ = regexp.MatchString(x[1:len(x)-1], rs)
 			} else {
-				match, _ = regexp.MatchString(x[1:len(x)-2], fmt.Sprintf("%v", result))
+				match, _ = regexp.MatchString(x[1:len(x)-1], fmt.Sprintf("%v", result))
 			}
-		} else if rs, ok := result.(string); ok {
-			match = (rs == x)
-		}
-		if !match {
-			return []string{}, result, expect
+			return match
 		}
+		rs, ok := result.(string)
+		return ok && rs == x
 	case float64:
 		switch r := result.(type) {
 		case float64:
-			if r != x {
-				return []string{}, result, expect
-			}
+			return r == x
 		case int64:
-			if float64(r) != x {
-				return []string{}, result, expect
-			}
+			return float64(r) == x
 		case int:
-			if float64(r) != x {
-				return []string{}, result, expect
-			}
-		default:
-			return []string{}, result, expect
+			return float64(r) == x
 		}
+		return false
 	case int64:
 		switch r := result.(type) {
 		case float64:
-			if r != float64(x) {
-				return []string{}, result, expect
-			}
+			return r == float64(x)
 		case int64:
-			if r != x {
-				return []string{}, result, expect
-			}
+			return r == x
 		case int:
-			if int64(r) != x {
-				return []string{}, result, expect
-			}
-		default:
-			return []string{}, result, expect
+			return int64(r) == x
 		}
+		return false
 	case int:
 		switch r := result.(type) {
 		case float64:
-			if r != float64(x) {
-				return []string{}, result, expect
-			}
+			return r == float64(x)
 		case int64:
-			if r != int64(x) {
-				return []string{}, result, expect
-			}
+			return r == int64(x)
 		case int:
-			if r != x {
-				return []string{}, result, expect
-			}
-		default:
-			return []string{}, result, expect
-		}
-	default:
-		if result != expect {
-			return []string{}, result, expect
+			return r == x
 		}
+		return false
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return result == expect
+}
+
+// matchString returns true if a string such as a header or cookie value
+// matches the expected value. Expected values that are not strings, such as
+// numbers and booleans, are compared with their string representation.
+func matchString(value string, expect interface{}) bool {
+	switch expect.(type) {
+	case string, map[string]interface{}, []interface{}, nil:
+		return matchValue(value, expect)
 	}
-	return nil, nil, nil
+	return value == fmt.Sprintf("%v", expect)
 }
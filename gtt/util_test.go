@@ -0,0 +1,123 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ohler55/ojg/sen"
+)
+
+// writeFiles writes the files, a map of slash separated path to content, to
+// a temporary directory and returns the directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// loadUseCases loads the use cases in the directory in the order given.
+func loadUseCases(t *testing.T, dir string, names ...string) []*UseCase {
+	t.Helper()
+	var ucs []*UseCase
+	for _, name := range names {
+		uc, err := NewUseCase(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ucs = append(ucs, uc)
+	}
+	return ucs
+}
+
+func TestMatch(t *testing.T) {
+	for _, c := range []struct {
+		actual string
+		expect string
+		paths  []string
+	}{
+		{actual: `{a: 1 b: [1 2]}`, expect: `{a: 1 b: [1 2]}`},
+		{actual: `{a: 1 b: 2 c: 3}`, expect: `{a: 1}`},
+		{actual: `{a: 2 b: {c: 3 d: [1 x]} e: 5}`, expect: `{a: 1 b: {c: 4 d: [1 y]} e: 5}`, paths: []string{"a", "b.c", "b.d.1"}},
+		{actual: `{a: [1]}`, expect: `{a: [1 2 3]}`, paths: []string{"a.1", "a.2"}},
+		{actual: `{a: [1 2 3]}`, expect: `{a: [1]}`, paths: []string{"a.1", "a.2"}},
+		{actual: `{a: 1 b: 2 c: null}`, expect: `{a: 1 "*": true}`, paths: []string{"b"}},
+		{actual: `{a: x}`, expect: `{a: {b: 1} c: 2}`, paths: []string{"a", "c"}},
+		{actual: `{a: abc}`, expect: `{a: "/^a.c$/"}`},
+		{actual: `[1 {a: 2}]`, expect: `[2 {a: 3}]`, paths: []string{"0", "1.a"}},
+	} {
+		mm := match(sen.MustParse([]byte(c.actual)), sen.MustParse([]byte(c.expect)))
+		paths := make([]string, 0, len(mm))
+		for _, m := range mm {
+			paths = append(paths, m.Path)
+		}
+		if strings.Join(paths, " ") != strings.Join(c.paths, " ") {
+			t.Errorf("%s vs %s: expected mismatches at %v, got %v", c.actual, c.expect, c.paths, paths)
+		}
+	}
+}
+
+func TestMatchDetails(t *testing.T) {
+	mm := match(sen.MustParse([]byte(`{a: [1 {b: x}] c: 2}`)), sen.MustParse([]byte(`{a: [1 {b: y}] d: 3}`)))
+	expect := []string{
+		`a.1.b $.a[1].b y x`,
+		`d $.d 3 <nil>`,
+	}
+	got := make([]string, 0, len(mm))
+	for _, m := range mm {
+		got = append(got, fmt.Sprintf("%s %s %v %v", m.Path, m.JSONPath, m.Expect, m.Actual))
+	}
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestStepMismatches(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"a":1,"b":"x","c":[1,2]}}`))
+	}))
+	defer ts.Close()
+
+	dir := writeFiles(t, map[string]string{
+		"mm.sen": `{steps: [{label: one content: "{a}" expect: {data: {a: 2 b: x c: [1 3]}}}]}`,
+	})
+	var events bytes.Buffer
+	r := Runner{
+		Server:    ts.URL,
+		Writer:    ioutil.Discard,
+		Reporters: []Reporter{&JSONReporter{Writer: &events}},
+		UseCases:  loadUseCases(t, dir, "mm.sen"),
+	}
+	rep, err := r.RunReport()
+	if err == nil {
+		t.Fatal("expected a mismatch")
+	}
+	sr := rep.UseCases[0].Steps[0]
+	var paths []string
+	for _, m := range sr.Mismatches {
+		paths = append(paths, m.Path)
+	}
+	if strings.Join(paths, " ") != "data.a data.c.1" || sr.Path != "data.a" {
+		t.Errorf("expected mismatches at data.a and data.c.1, got %v with path %s", paths, sr.Path)
+	}
+	if n := strings.Count(events.String(), `"kind":"mismatch"`); n != 2 {
+		t.Errorf("expected 2 mismatch events, got %d", n)
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+
+	// wsMaxMessage is the largest message accepted from a server. Larger
+	// frames or fragmented messages result in an error instead of an
+	// allocation of the size claimed by the peer.
+	wsMaxMessage = 16 << 20
+)
+
+// wsConn is a minimal client side WebSocket connection as described by RFC
+// 6455. It supports only what is needed by the GraphQL subscription
+// protocols, text messages and the control frames.
+type wsConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// dialFunc is a function that opens a network connection.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// dialWebSocket opens a WebSocket connection to the ws or wss URL with the
+// sub-protocol provided. The deadline of the context, if any, is applied to
+// all reads and writes on the connection. If dial is nil a net.Dialer is
+// used and if tc is nil a default TLS configuration is used.
+func dialWebSocket(
+	ctx context.Context,
+	rawURL string,
+	protocol string,
+	header http.Header,
+	dial dialFunc,
+	tc *tls.Config) (ws *wsConn, err error) {
+
+	var u *url.URL
+	if u, err = url.Parse(rawURL); err != nil {
+		return
+	}
+	addr := u.Host
+	httpURL := *u
+	switch u.Scheme {
+	case "ws":
+		httpURL.Scheme = "http"
+		if len(u.Port()) == 0 {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	case "wss":
+		httpURL.Scheme = "https"
+		if len(u.Port()) == 0 {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		}
+	default:
+		return nil, fmt.Errorf("%s is not a WebSocket URL", rawURL)
+	}
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	var conn net.Conn
+	if conn, err = dial(ctx, "tcp", addr); err != nil {
+		return
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if u.Scheme == "wss" {
+		if tc == nil {
+			tc = &tls.Config{}
+		} else {
+			tc = tc.Clone()
+		}
+		if len(tc.ServerName) == 0 {
+			tc.ServerName = u.Hostname()
+		}
+		tlsConn := tls.Client(conn, tc)
+		if err = tlsConn.Handshake(); err != nil {
+			_ = conn.Close()
+			return
+		}
+		conn = tlsConn
+	}
+	ws = &wsConn{conn: conn, rd: bufio.NewReader(conn)}
+	if err = ws.handshake(ctx, &httpURL, protocol, header); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return
+}
+
+func (ws *wsConn) handshake(ctx context.Context, u *url.URL, protocol string, header http.Header) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	copyHeader(req.Header, header)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if 0 < len(protocol) {
+		req.Header.Set("Sec-WebSocket-Protocol", protocol)
+	}
+	if err = req.Write(ws.conn); err != nil {
+		return err
+	}
+	var res *http.Response
+	if res, err = http.ReadResponse(ws.rd, req); err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("WebSocket upgrade failed with status %d. %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+	sum := sha1.Sum([]byte(key + wsGUID))
+	if res.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		return fmt.Errorf("WebSocket upgrade returned an invalid Sec-WebSocket-Accept header")
+	}
+	if 0 < len(protocol) && res.Header.Get("Sec-WebSocket-Protocol") != protocol {
+		return fmt.Errorf("WebSocket server did not accept the %s protocol", protocol)
+	}
+	return nil
+}
+
+// write a single masked frame.
+func (ws *wsConn) write(opcode byte, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xFFFF:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return err
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := ws.conn.Write(frame)
+
+	return err
+}
+
+// readFrame reads a single frame and returns the fin flag, opcode, and
+// payload.
+func (ws *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	head := make([]byte, 2)
+	if _, err = io.ReadFull(ws.rd, head); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	masked := head[1]&0x80 != 0
+	size := uint64(head[1] & 0x7F)
+	switch size {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(ws.rd, ext); err != nil {
+			return
+		}
+		size = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(ws.rd, ext); err != nil {
+			return
+		}
+		size = binary.BigEndian.Uint64(ext)
+	}
+	if wsMaxMessage < size {
+		return fin, opcode, nil, fmt.Errorf("WebSocket frame of %d bytes exceeds the %d byte limit", size, wsMaxMessage)
+	}
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err = io.ReadFull(ws.rd, mask); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(ws.rd, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// read the next text or binary message. Ping frames are answered and a
+// close frame results in an io.EOF error.
+func (ws *wsConn) read() ([]byte, error) {
+	var msg []byte
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err = ws.write(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			_ = ws.write(wsClose, payload)
+			return nil, io.EOF
+		case wsText, wsBinary, wsContinuation:
+			if wsMaxMessage < len(msg)+len(payload) {
+				return nil, fmt.Errorf("WebSocket message exceeds the %d byte limit", wsMaxMessage)
+			}
+			msg = append(msg, payload...)
+		}
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// writeJSON writes the value as a JSON text message.
+func (ws *wsConn) writeJSON(v interface{}) error {
+	return ws.write(wsText, []byte(oj.JSON(v)))
+}
+
+// readJSON reads a message that is expected to be a JSON object.
+func (ws *wsConn) readJSON() (map[string]interface{}, error) {
+	data, err := ws.read()
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if v, err = oj.Parse(data); err != nil {
+		return nil, err
+	}
+	msg, _ := v.(map[string]interface{})
+	if msg == nil {
+		return nil, fmt.Errorf("expected a JSON object message, not %s", data)
+	}
+	return msg, nil
+}
+
+// close the connection after sending a normal closure frame.
+func (ws *wsConn) close() error {
+	_ = ws.write(wsClose, []byte{0x03, 0xE8})
+	return ws.conn.Close()
+}
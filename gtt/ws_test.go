@@ -0,0 +1,337 @@
+// Copyright (c) 2019, Peter Ohler, All rights reserved.
+
+package gtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ohler55/ojg/oj"
+)
+
+// wsPeer is the server side of a WebSocket connection. Frames from the
+// client must be masked and frames to the client are not masked.
+type wsPeer struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// wsServer upgrades requests for the sub-protocol and then calls handle.
+// The result of each handle call is sent on the returned channel.
+func wsServer(protocol string, handle func(p *wsPeer) error) (*httptest.Server, chan error) {
+	h, results := wsHandler(protocol, handle)
+	return httptest.NewServer(h), results
+}
+
+// wsHandler is the handler of wsServer.
+func wsHandler(protocol string, handle func(p *wsPeer) error) (http.Handler, chan error) {
+	results := make(chan error, 4)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Sec-WebSocket-Version") != "13" ||
+			r.Header.Get("Sec-WebSocket-Protocol") != protocol {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte("not a WebSocket upgrade"))
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			results <- err
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		sum := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID))
+		fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+			"Upgrade: websocket\r\nConnection: Upgrade\r\n"+
+			"Sec-WebSocket-Accept: %s\r\nSec-WebSocket-Protocol: %s\r\n\r\n",
+			base64.StdEncoding.EncodeToString(sum[:]), protocol)
+		if err = rw.Flush(); err != nil {
+			results <- err
+			return
+		}
+		results <- handle(&wsPeer{conn: conn, rd: rw.Reader})
+	}), results
+}
+
+func (p *wsPeer) write(fin bool, opcode byte, payload []byte) error {
+	head := []byte{opcode, 0}
+	if fin {
+		head[0] |= 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		head[1] = byte(n)
+	case n <= 0xFFFF:
+		head[1] = 126
+		head = append(head, 0, 0)
+		binary.BigEndian.PutUint16(head[2:], uint16(n))
+	default:
+		head[1] = 127
+		head = append(head, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(head[2:], uint64(n))
+	}
+	_, err := p.conn.Write(append(head, payload...))
+	return err
+}
+
+func (p *wsPeer) read() (opcode byte, payload []byte, err error) {
+	ws := wsConn{conn: p.conn, rd: p.rd}
+	if head, perr := p.rd.Peek(2); perr == nil && head[1]&0x80 == 0 {
+		return 0, nil, fmt.Errorf("client frame not masked")
+	}
+	var fin bool
+	if fin, opcode, payload, err = ws.readFrame(); err == nil && !fin {
+		err = fmt.Errorf("client frame not final")
+	}
+	return
+}
+
+func (p *wsPeer) expect(opcode byte, payload []byte) error {
+	op, data, err := p.read()
+	if err != nil {
+		return err
+	}
+	if op != opcode || !bytes.Equal(data, payload) {
+		return fmt.Errorf("expected opcode %d with %d bytes, got opcode %d with %d bytes", opcode, len(payload), op, len(data))
+	}
+	return nil
+}
+
+func (p *wsPeer) writeJSON(v interface{}) error {
+	return p.write(true, wsText, []byte(oj.JSON(v)))
+}
+
+func (p *wsPeer) expectJSON(expect string) error {
+	op, data, err := p.read()
+	if err != nil {
+		return err
+	}
+	if op != wsText {
+		return fmt.Errorf("expected a text frame, got opcode %d", op)
+	}
+	var v interface{}
+	if v, err = oj.Parse(data); err != nil {
+		return err
+	}
+	if mm := match(v, oj.MustParseString(expect)); 0 < len(mm) {
+		return fmt.Errorf("expected %s, got %s", expect, data)
+	}
+	return nil
+}
+
+func wsURL(ts *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(ts.URL, "http")
+}
+
+func waitPeer(t *testing.T, results chan error) {
+	t.Helper()
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("server did not finish")
+	}
+}
+
+func TestWSFrames(t *testing.T) {
+	medium := bytes.Repeat([]byte("m"), 300)
+	large := bytes.Repeat([]byte("l"), 70000)
+	ts, results := wsServer("test", func(p *wsPeer) error {
+		for _, payload := range [][]byte{[]byte("hello server"), medium, large} {
+			if err := p.expect(wsText, payload); err != nil {
+				return err
+			}
+		}
+		// A fragmented message with a ping between the fragments.
+		if err := p.write(false, wsText, []byte("hel")); err != nil {
+			return err
+		}
+		if err := p.write(true, wsPing, []byte("p")); err != nil {
+			return err
+		}
+		if err := p.write(true, wsContinuation, []byte("lo")); err != nil {
+			return err
+		}
+		if err := p.expect(wsPong, []byte("p")); err != nil {
+			return err
+		}
+		if err := p.write(true, wsText, medium); err != nil {
+			return err
+		}
+		if err := p.write(true, wsBinary, large); err != nil {
+			return err
+		}
+		if err := p.write(true, wsClose, []byte{0x03, 0xE8}); err != nil {
+			return err
+		}
+		return p.expect(wsClose, []byte{0x03, 0xE8})
+	})
+	defer ts.Close()
+
+	ws, err := dialWebSocket(context.Background(), wsURL(ts), "test", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ws.conn.Close() }()
+	for _, payload := range [][]byte{[]byte("hello server"), medium, large} {
+		if err = ws.write(wsText, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, expect := range [][]byte{[]byte("hello"), medium, large} {
+		msg, err := ws.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(msg, expect) {
+			t.Errorf("expected a %d byte message, got %d bytes", len(expect), len(msg))
+		}
+	}
+	if _, err = ws.read(); err != io.EOF {
+		t.Errorf("expected io.EOF after a close frame, got %v", err)
+	}
+	waitPeer(t, results)
+}
+
+func TestWSFrameLimit(t *testing.T) {
+	ts, results := wsServer("test", func(p *wsPeer) error {
+		// A text frame that claims a 2^62 byte payload.
+		_, err := p.conn.Write([]byte{0x81, 127, 0x40, 0, 0, 0, 0, 0, 0, 0})
+		return err
+	})
+	defer ts.Close()
+
+	ws, err := dialWebSocket(context.Background(), wsURL(ts), "test", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ws.conn.Close() }()
+	if _, err = ws.read(); err == nil || !strings.Contains(err.Error(), "exceeds") {
+		t.Errorf("expected a frame size error, got %v", err)
+	}
+	waitPeer(t, results)
+}
+
+func TestWSHandshake(t *testing.T) {
+	ts, _ := wsServer("test", func(p *wsPeer) error { return nil })
+	defer ts.Close()
+
+	if _, err := dialWebSocket(context.Background(), wsURL(ts), "other", nil, nil, nil); err == nil ||
+		!strings.Contains(err.Error(), "status 400") {
+		t.Errorf("expected an upgrade failure, got %v", err)
+	}
+	if _, err := dialWebSocket(context.Background(), ts.URL, "test", nil, nil, nil); err == nil {
+		t.Error("expected an error for an http URL")
+	}
+}
+
+func TestSubscriptionWS(t *testing.T) {
+	for _, c := range []struct {
+		protocol string
+		count    int
+	}{
+		{protocol: TransportWS},
+		{protocol: TransportWS, count: 1},
+		{protocol: LegacyWS},
+		{protocol: LegacyWS, count: 1},
+	} {
+		t.Run(fmt.Sprintf("%s count %d", c.protocol, c.count), func(t *testing.T) {
+			legacy := c.protocol == LegacyWS
+			ts, results := wsServer(c.protocol, func(p *wsPeer) error {
+				if err := p.expectJSON(`{"type":"connection_init","payload":{"token":"t1"}}`); err != nil {
+					return err
+				}
+				if legacy {
+					if err := p.writeJSON(map[string]interface{}{"type": "connection_ack"}); err != nil {
+						return err
+					}
+					// Keep alive messages are ignored.
+					if err := p.writeJSON(map[string]interface{}{"type": "ka"}); err != nil {
+						return err
+					}
+				} else {
+					if err := p.writeJSON(map[string]interface{}{"type": "ping"}); err != nil {
+						return err
+					}
+					if err := p.expectJSON(`{"type":"pong"}`); err != nil {
+						return err
+					}
+					if err := p.writeJSON(map[string]interface{}{"type": "connection_ack"}); err != nil {
+						return err
+					}
+				}
+				start, next := "subscribe", "next"
+				if legacy {
+					start, next = "start", "data"
+				}
+				if err := p.expectJSON(`{"id":"1","type":"` + start + `","payload":{"query":"subscription {n}"}}`); err != nil {
+					return err
+				}
+				for n := 1; n <= 2; n++ {
+					ev := map[string]interface{}{"id": "1", "type": next, "payload": map[string]interface{}{"data": map[string]interface{}{"n": n}}}
+					if err := p.writeJSON(ev); err != nil {
+						return err
+					}
+					if n == c.count {
+						break
+					}
+				}
+				switch {
+				case c.count == 0:
+					if err := p.writeJSON(map[string]interface{}{"id": "1", "type": "complete"}); err != nil {
+						return err
+					}
+				case legacy:
+					if err := p.expectJSON(`{"id":"1","type":"stop"}`); err != nil {
+						return err
+					}
+					if err := p.expectJSON(`{"type":"connection_terminate"}`); err != nil {
+						return err
+					}
+				default:
+					if err := p.expectJSON(`{"id":"1","type":"complete"}`); err != nil {
+						return err
+					}
+				}
+				return p.expect(wsClose, []byte{0x03, 0xE8})
+			})
+			defer ts.Close()
+
+			expect := "[{data: {n: 1}} {data: {n: 2}}]"
+			if c.count == 1 {
+				expect = "[{data: {n: 1}}]"
+			}
+			dir := writeFiles(t, map[string]string{
+				"sub.sen": fmt.Sprintf(`{steps: [{
+  label: sub
+  content: "subscription {n}"
+  subscribe: {protocol: %s count: %d init: {token: t1}}
+  expect: %s
+}]}`, c.protocol, c.count, expect),
+			})
+			r := Runner{
+				Server:   ts.URL,
+				Writer:   ioutil.Discard,
+				UseCases: loadUseCases(t, dir, "sub.sen"),
+			}
+			if err := r.Run(); err != nil {
+				t.Fatal(err)
+			}
+			waitPeer(t, results)
+		})
+	}
+}